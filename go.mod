@@ -4,6 +4,10 @@ go 1.25.5
 
 require (
 	github.com/litao91/goldmark-mathjax v0.0.0-20210217064022-a43cf739a50f
+	github.com/pelletier/go-toml/v2 v2.2.4
 	github.com/yuin/goldmark v1.7.16
+	go.etcd.io/bbolt v1.4.3
 	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/sys v0.29.0 // indirect
@@ -1,110 +1,227 @@
 package main
 
 import (
+	"bytes"
 	"embed"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"html/template"
 	"io"
+	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"time"
 
 	"github.com/iashyam/gossg/src"
 	"gopkg.in/yaml.v3"
 )
 
-type Config struct {
-	BaseURL      string `yaml:"baseURL"`
-	SiteName     string `yaml:"siteName"`
-	CustomDomain string `yaml:"customDomain"`
-}
-
-func loadConfig() Config {
-	var cfg Config
+// loadConfig reads config.yaml, or config.yml. Without either the defaults
+// are used.
+func loadConfig() (src.Config, error) {
+	var cfg src.Config
 
 	// try .yaml first
-	data, err := os.ReadFile("config.yaml")
+	name := "config.yaml"
+	data, err := os.ReadFile(name)
 	if err != nil {
 		// fallback to .yml
-		data, err = os.ReadFile("config.yml")
+		name = "config.yml"
+		data, err = os.ReadFile(name)
 	}
 
 	if err == nil {
 		if err := yaml.Unmarshal(data, &cfg); err != nil {
-			fmt.Printf("Warning: Failed to parse config file: %v\n", err)
-		} else {
-			fmt.Printf("Loaded config with BaseURL: %s\n", cfg.BaseURL)
+			return cfg, &src.FileError{File: name, Err: err}
 		}
+		slog.Debug("loaded config", "baseURL", cfg.BaseURL)
 	} else {
-		fmt.Println("Warning: No config.yaml or config.yml found. Using default paths.")
+		slog.Warn("no config.yaml or config.yml found, using default paths")
 	}
 
 	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
-	return cfg
+	return cfg, nil
 }
 
 //go:embed src/templates/*
 var templatesFS embed.FS
 
+// options are the command-line flags that affect a build.
+type options struct {
+	workers int
+	metrics string
+	quiet   bool
+	// strict and maxWarnings fail a build with too many warnings, as
+	// counted by warnings.
+	strict      bool
+	maxWarnings int
+	warnings    func() int
+}
+
 func main() {
+	var opts options
+	flag.IntVar(&opts.workers, "workers", runtime.NumCPU(), "number of files loaded or rendered at once")
+	flag.StringVar(&opts.metrics, "metrics", "", "write the build report as JSON to this file, - for stdout")
+	flag.BoolVar(&opts.quiet, "quiet", false, "only log warnings and errors, and skip the build report")
+	verbose := flag.Bool("verbose", false, "also log debug messages, like cache hits")
+	logFormat := flag.String("log-format", "text", "log format, text or json")
+	flag.BoolVar(&opts.strict, "strict", false, "fail the build if there are any warnings")
+	flag.IntVar(&opts.maxWarnings, "max-warnings", -1, "fail the build if there are more warnings than this")
+	flag.Parse()
+
+	// Logs go to stderr, leaving stdout for the report
+	level := slog.LevelInfo
+	switch {
+	case opts.quiet && *verbose:
+		fmt.Fprintln(os.Stderr, "--quiet and --verbose can't be used together")
+		os.Exit(2)
+	case opts.quiet:
+		level = slog.LevelWarn
+	case *verbose:
+		level = slog.LevelDebug
+	}
+	logs, err := src.NewLogHandler(os.Stderr, level, *logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.SetDefault(slog.New(logs))
+	opts.warnings = logs.Warnings
+
+	// gossg cache clear|stats|prune manages the render cache instead of building
+	if flag.Arg(0) == "cache" {
+		if err := cacheCommand(opts, flag.Args()[1:]); err != nil {
+			src.LogErrors("cache command failed", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := build(opts); err != nil {
+		n := src.LogErrors("build failed", err)
+		slog.Error("build failed, public is unchanged", "errors", n)
+		os.Exit(1)
+	}
+
+	slog.Info("site generated", "dir", "public", "warnings", logs.Warnings())
+}
+
+// newSite loads the config and everything needed to render content: the
+// cache, template functions, partials and shortcodes.
+func newSite(opts options) (*src.Site, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	site := src.NewSite(cfg)
+	site.Workers = opts.workers
+	store, err := src.OpenCacheStore(cfg.Cache)
+	if err != nil {
+		return nil, err
+	}
+	site.Cache = src.NewCache(store)
+
+	// Shortcodes can't link to assets: their output is cached with the
+	// Markdown, which isn't rendered again when an asset changes
+	funcMap := src.FuncMap(cfg)
+	tmplFuncs := maps.Clone(funcMap)
+	maps.Copy(tmplFuncs, site.Assets.Funcs())
+	site.Partials, err = src.LoadPartials(templatesFS, "src/templates/partials", "layouts/partials", tmplFuncs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load partials: %w", err)
+	}
+	for name, fn := range site.Partials.Funcs() {
+		funcMap[name] = fn
+	}
+	site.Shortcodes, err = src.LoadShortcodes(templatesFS, "src/templates/shortcodes", "layouts/shortcodes", ".", funcMap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load shortcodes: %w", err)
+	}
+	return site, nil
+}
+
+// build generates the site into public. Nothing in public changes unless
+// every stage succeeds.
+func build(opts options) error {
+	report := src.NewReport()
+
 	// 1. Initialize Site and Config
-	site := src.NewSite()
-	cfg := loadConfig()
+	site, err := newSite(opts)
+	if err != nil {
+		return err
+	}
+	cfg := site.Config
 
 	// 2. Load Content
-	fmt.Println("Loading content...")
+	slog.Info("loading content")
+	start := time.Now()
 	if err := site.LoadContent("content"); err != nil {
-		fmt.Printf("Error loading content: %v\n", err)
-		return
+		return err
 	}
+	report.Phase("load", start)
 
 	// 3. Setup output directory
-	if err := os.RemoveAll("public"); err != nil {
-		fmt.Printf("Error clearing public dir: %v\n", err)
-		return
-	}
-	if err := os.MkdirAll("public/posts", 0755); err != nil {
-		fmt.Printf("Error creating public dir: %v\n", err)
-		return
-	}
-	if err := os.MkdirAll("public/tags", 0755); err != nil {
-		fmt.Printf("Error creating public tags dir: %v\n", err)
-		return
+	// Outputs are only rewritten when the inputs they were built from change
+	builder := src.NewBuilder("public", ".gossg_manifest.json")
+	cfgJSON, _ := json.Marshal(cfg)
+	builder.SetInput("gossg", src.ComputeHash([]byte(src.BuildVersion())))
+	builder.SetInput("config", src.ComputeHash(cfgJSON))
+	builder.SetInput("theme", src.HashFS(templatesFS, "src/templates")+src.HashFS(os.DirFS("."), "layouts"))
+	builder.SetInput("data", src.HashFS(os.DirFS("."), "data"))
+	builder.SetInput("site", site.IndexHash())
+	// Pages list and link to other content through .Site, so they depend on
+	// its metadata. The rendered content of a file only goes into its own page
+	common := []string{"gossg", "config", "theme", "data", "assets", "site"}
+	inputs := func(extra ...string) []string {
+		return append(append([]string{}, common...), extra...)
 	}
 
 	if cfg.CustomDomain != "" {
-		if err := os.WriteFile("public/CNAME", []byte(cfg.CustomDomain), 0644); err != nil {
-			fmt.Printf("Warning: failed to write CNAME file: %v\n", err)
+		err := builder.Render("CNAME", []string{"config"}, func(w io.Writer) error {
+			_, err := io.WriteString(w, cfg.CustomDomain)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write CNAME file: %w", err)
 		}
 	}
 
 	// 4. Copy Static Assets
-	fmt.Println("Copying assets...")
-	if err := copyDir("content/assets", "public/assets"); err != nil {
-		fmt.Printf("Warning: failed to copy assets: %v\n", err)
+	slog.Info("copying assets")
+	start = time.Now()
+	if err := site.Assets.Load("content/assets"); err != nil {
+		return fmt.Errorf("failed to load assets: %w", err)
 	}
-
-	// 5. Load Templates
-	funcMap := template.FuncMap{
-		"url": func(path string) string {
-			path = strings.TrimSpace(path)
-			if !strings.HasPrefix(path, "/") {
-				path = "/" + path
-			}
-			return cfg.BaseURL + path
-		},
-		"siteName": func() string {
-			if cfg.SiteName != "" {
-				return cfg.SiteName
-			}
-			return "The Rest Frame"
-		},
-		"lower": strings.ToLower,
+	if err := site.Assets.Build("assets"); err != nil {
+		return fmt.Errorf("failed to build assets: %w", err)
+	}
+	if err := site.Assets.Write(builder); err != nil {
+		return fmt.Errorf("failed to copy assets: %w", err)
 	}
+	report.Phase("assets", start)
 
+	// 5. Load Templates
+	// Every template set starts with the partials. Sets are named after
+	// their last file in the build report.
+	start = time.Now()
+	tmplNames := map[*template.Template]string{}
+	var tmplErrs []error
 	parseTmpl := func(files ...string) *template.Template {
-		t := template.New(filepath.Base(files[0])).Funcs(funcMap)
-		return template.Must(t.ParseFS(templatesFS, files...))
+		t, err := site.Partials.Clone()
+		if err == nil {
+			t, err = t.New(filepath.Base(files[0])).ParseFS(templatesFS, files...)
+		}
+		if err != nil {
+			tmplErrs = append(tmplErrs, err)
+			return nil
+		}
+		tmplNames[t] = filepath.Base(files[len(files)-1])
+		return t
 	}
 
 	postTmpl := parseTmpl("src/templates/base.html", "src/templates/post.html")
@@ -112,157 +229,195 @@ func main() {
 	listTmpl := parseTmpl("src/templates/base.html", "src/templates/list.html")
 	tagsTmpl := parseTmpl("src/templates/base.html", "src/templates/tags.html")
 	projTmpl := parseTmpl("src/templates/base.html", "src/templates/projects.html")
+	authorTmpl := parseTmpl("src/templates/base.html", "src/templates/author.html")
+	if err := errors.Join(tmplErrs...); err != nil {
+		return err
+	}
+
+	siteCtx := site.Context()
+	pageCtx := func(page any) src.PageContext {
+		return src.PageContext{Page: page, Site: siteCtx}
+	}
+
+	// Outputs are collected first and rendered concurrently below
+	var outputs []output
+	tmplTimes := src.NewTimings()
+	generateFile := func(path string, inputs []string, tmpl *template.Template, data any) {
+		outputs = append(outputs, output{path, inputs, func(w io.Writer) error {
+			var page bytes.Buffer
+			start := time.Now()
+			err := tmpl.Execute(&page, data)
+			tmplTimes.Add(tmplNames[tmpl], time.Since(start))
+			if err != nil {
+				return err
+			}
+			if cfg.Minify.HTML {
+				_, err = w.Write(src.MinifyHTML(page.Bytes()))
+				return err
+			}
+			_, err = page.WriteTo(w)
+			return err
+		}})
+	}
 
 	// 5. Generate Pages
 	for _, page := range site.Pages {
-		generateFile(filepath.Join("public", page.Slug+".html"), postTmpl, page)
+		generateFile(page.Slug+".html", inputs(append([]string{page.File}, page.Includes...)...), postTmpl, pageCtx(page))
 	}
 
 	// 6. Generate Posts
 	for _, post := range site.Posts {
-		generateFile(filepath.Join("public", "posts", post.Slug+".html"), postTmpl, post)
+		generateFile(filepath.Join("posts", post.Slug+".html"), inputs(append([]string{post.File}, post.Includes...)...), postTmpl, pageCtx(post))
 	}
 
 	// 7. Generate Home Page (Index) with Pagination
 	postsPerPage := 5
-	totalPosts := len(site.Posts)
-	totalPages := (totalPosts + postsPerPage - 1) / postsPerPage
-	if totalPages == 0 {
-		totalPages = 1
-	}
-
-	for pageNum := 1; pageNum <= totalPages; pageNum++ {
-		startIdx := (pageNum - 1) * postsPerPage
-		endIdx := startIdx + postsPerPage
-		if endIdx > totalPosts {
-			endIdx = totalPosts
-		}
-
-		pagePosts := site.Posts[startIdx:endIdx]
-
-		data := map[string]interface{}{
-			"Title":       "Home",
-			"Posts":       pagePosts,
-			"CurrentPage": pageNum,
-			"TotalPages":  totalPages,
-			"PrevPage":    pageNum - 1,
-			"NextPage":    pageNum + 1,
-		}
-
-		var outputPath string
-		if pageNum == 1 {
-			outputPath = "public/index.html"
-		} else {
-			os.MkdirAll(fmt.Sprintf("public/page/%d", pageNum), 0755)
-			outputPath = fmt.Sprintf("public/page/%d/index.html", pageNum)
-		}
-
-		generateFile(outputPath, indexTmpl, data)
+	for _, pager := range src.Paginate(site.Posts, postsPerPage) {
+		ctx := pageCtx(src.ListPage{Title: "Home", Posts: pager.Posts})
+		ctx.Paginator = pager
+		generateFile(strings.TrimPrefix(pager.URL(), "/"), common, indexTmpl, ctx)
 	}
 
 	// 8. Generate Timeline
-	generateFile("public/timeline.html", listTmpl, map[string]interface{}{
-		"Title": "Timeline",
-		"Posts": site.Posts,
-	})
+	generateFile("timeline.html", common, listTmpl, pageCtx(src.ListPage{
+		Title: "Timeline",
+		Posts: site.Posts,
+	}))
 
 	// 8. Generate Tags Index
-	generateFile("public/tags.html", tagsTmpl, map[string]interface{}{
-		"Title": "All Tags",
-		"Tags":  site.Tags,
-	})
+	generateFile("tags.html", common, tagsTmpl, pageCtx(src.ListPage{Title: "All Tags"}))
 
 	// 9. Generate Projects Page
-	generateFile("public/projects.html", projTmpl, map[string]interface{}{
-		"Title":    "Projects",
-		"Projects": site.Projects,
-	})
+	generateFile("projects.html", common, projTmpl, pageCtx(src.ListPage{Title: "Projects"}))
 
 	// 10. Generate Individual Tag Pages
 	for tag, posts := range site.Tags {
-		generateFile(filepath.Join("public", "tags", tag+".html"), listTmpl, map[string]interface{}{
-			"Title": "Tag: " + tag,
-			"Posts": posts,
-		})
+		generateFile(filepath.Join("tags", tag+".html"), common, listTmpl, pageCtx(src.ListPage{
+			Title: "Tag: " + tag,
+			Posts: posts,
+		}))
 	}
 
-	fmt.Println("Site generation complete! Check the 'public' directory.")
-}
-
-func generateFile(outputPath string, tmpl *template.Template, data interface{}) {
-	file, err := os.Create(outputPath)
-	if err != nil {
-		fmt.Printf("Failed to create file %s: %v\n", outputPath, err)
-		return
+	// 11. Generate Author Pages and Feeds
+	for id, author := range site.Authors {
+		generateFile(filepath.Join("authors", id+".html"), common, authorTmpl, pageCtx(author))
+		outputs = append(outputs, output{filepath.Join("authors", id+".xml"), common, func(w io.Writer) error {
+			defer func(start time.Time) { tmplTimes.Add("feed", time.Since(start)) }(time.Now())
+			return src.WriteFeed(w, author.Name, cfg.BaseURL+"/authors/"+id+".html", cfg.BaseURL, author.Posts)
+		}})
 	}
-	defer file.Close()
 
-	if err := tmpl.Execute(file, data); err != nil {
-		fmt.Printf("Failed to execute template for %s: %v\n", outputPath, err)
-	}
-}
-
-// copyDir recursively copies a directory tree, attempting to preserve permissions.
-// Source directory must exist.
-func copyDir(src string, dst string) error {
-	srcInfo, err := os.Stat(src)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // Source directory doesn't exist, ignore
+	// Every output is rendered, even after errors, so that one build
+	// reports all of them
+	renderErrs := make([]error, len(outputs))
+	src.Parallel(len(outputs), opts.workers, func(i int) {
+		out := outputs[i]
+		if err := builder.Render(out.path, out.inputs, out.render); err != nil {
+			renderErrs[i] = fmt.Errorf("failed to render %s: %w", out.path, err)
 		}
+	})
+	if err := errors.Join(renderErrs...); err != nil {
 		return err
 	}
+	report.Phase("render", start)
 
-	if err := os.MkdirAll(dst, srcInfo.Mode()); err != nil {
-		return err
+	// Warnings fail the build only when asked to, and before public changes
+	if n := opts.warnings(); opts.strict && n > 0 || opts.maxWarnings >= 0 && n > opts.maxWarnings {
+		return fmt.Errorf("too many warnings: %d", n)
 	}
 
-	entries, err := os.ReadDir(src)
-	if err != nil {
+	// 12. Write the outputs and remove those this build no longer produces
+	start = time.Now()
+	if err := builder.Finish(); err != nil {
 		return err
 	}
-
-	for _, entry := range entries {
-		srcPath := filepath.Join(src, entry.Name())
-		dstPath := filepath.Join(dst, entry.Name())
-
-		if entry.IsDir() {
-			err = copyDir(srcPath, dstPath)
-			if err != nil {
-				return err
-			}
-		} else {
-			err = copyFile(srcPath, dstPath)
-			if err != nil {
-				return err
-			}
+	report.Phase("write", start)
+
+	report.Finish(site, builder, tmplTimes)
+	if !opts.quiet {
+		// Keep stdout parseable when it holds the JSON report
+		table := io.Writer(os.Stdout)
+		if opts.metrics == "-" {
+			table = os.Stderr
+		}
+		if err := report.WriteTable(table); err != nil {
+			return fmt.Errorf("failed to print build report: %w", err)
+		}
+	}
+	if opts.metrics != "" {
+		if err := writeMetrics(opts.metrics, report); err != nil {
+			return fmt.Errorf("failed to write build metrics: %w", err)
 		}
 	}
 	return nil
 }
 
-// copyFile copies a single file from src to dst
-func copyFile(src, dst string) error {
-	in, err := os.Open(src)
+// output is a file of the site, rendered by render when any of inputs
+// changed since the last build.
+type output struct {
+	path   string
+	inputs []string
+	render func(w io.Writer) error
+}
+
+// writeMetrics writes report as JSON to path, or to stdout for "-".
+func writeMetrics(path string, report *src.Report) error {
+	if path == "-" {
+		return report.WriteJSON(os.Stdout)
+	}
+	f, err := os.Create(path)
 	if err != nil {
 		return err
 	}
-	defer in.Close()
-
-	out, err := os.Create(dst)
-	if err != nil {
+	if err := report.WriteJSON(f); err != nil {
+		f.Close()
 		return err
 	}
-	defer out.Close()
+	return f.Close()
+}
 
-	_, err = io.Copy(out, in)
+// cacheCommand runs "gossg cache <command>".
+func cacheCommand(opts options, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: gossg cache clear|stats|prune")
+	}
+	site, err := newSite(opts)
 	if err != nil {
 		return err
 	}
+	cache := site.Cache
+	cache.Fingerprint = site.CacheFingerprint()
 
-	srcInfo, err := os.Stat(src)
-	if err != nil {
-		return err
+	switch args[0] {
+	case "clear":
+		if err := cache.Clear(); err != nil {
+			return err
+		}
+		fmt.Println("Cache cleared.")
+	case "stats":
+		stats, err := cache.Stats()
+		if err != nil {
+			return err
+		}
+		if stats.Bytes == 0 {
+			fmt.Printf("No cache at %s.\n", stats.Path)
+			return nil
+		}
+		status := "current"
+		if !stats.Current {
+			status = "outdated, rendered by another gossg version or config"
+		}
+		fmt.Printf("Cache:   %s (%d bytes)\n", stats.Path, stats.Bytes)
+		fmt.Printf("Version: %d (%s)\n", stats.Version, status)
+		fmt.Printf("Entries: %d, %d stale\n", stats.Entries, stats.Stale)
+	case "prune":
+		removed, err := cache.Prune()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d cache entries, %d left.\n", removed, len(cache.Files))
+	default:
+		return fmt.Errorf("unknown cache command %q, want clear, stats or prune", args[0])
 	}
-	return os.Chmod(dst, srcInfo.Mode())
+	return nil
 }
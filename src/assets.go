@@ -0,0 +1,323 @@
+package src
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Asset is a published static file.
+type Asset struct {
+	// Path is where the file is published, relative to the site root. With
+	// fingerprinting it holds a hash of the content, like
+	// assets/css/site.3f2a1c9e.css.
+	Path string `json:"path"`
+	// Integrity is the Subresource Integrity hash of the content.
+	Integrity string `json:"integrity"`
+
+	// src is the file published, or data its content when it was built.
+	src  string
+	data []byte
+}
+
+// Assets publishes the files of an assets directory and resolves their
+// names, like "css/site.css", to the URLs they are published at.
+type Assets struct {
+	dir         string
+	baseURL     string
+	fingerprint bool
+	minify      bool
+	assets      map[string]Asset
+}
+
+// AssetManifest is where Assets lists what it published, relative to the
+// output directory.
+const AssetManifest = "assets/manifest.json"
+
+// NewAssets returns an empty Assets publishing under dir of the site.
+func NewAssets(dir string, cfg Config) *Assets {
+	return &Assets{
+		dir:         dir,
+		baseURL:     cfg.BaseURL,
+		fingerprint: cfg.Assets.Fingerprint,
+		minify:      cfg.Minify.Assets,
+		assets:      map[string]Asset{},
+	}
+}
+
+// Load adds every file under src as it is.
+func (a *Assets) Load(src string) error {
+	return walkAssets(src, func(name, file string) error {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		return a.add(name, file, content, false)
+	})
+}
+
+// Build adds every file under src, bundling each stylesheet and script
+// with the files it imports and minifying them when configured to. Other
+// files are added as they are. Files and directories whose names start with
+// an underscore are only imported, not published.
+func (a *Assets) Build(src string) error {
+	fsys := os.DirFS(src)
+	return walkAssets(src, func(name, file string) error {
+		if slices.ContainsFunc(strings.Split(name, "/"), func(elem string) bool { return strings.HasPrefix(elem, "_") }) {
+			return nil
+		}
+
+		var content []byte
+		var err error
+		switch path.Ext(name) {
+		case ".css":
+			if content, err = bundle(fsys, name, cssImportRe, inlineCSS); err != nil {
+				return &FileError{File: file, Err: err}
+			}
+			content = hoistImports(content)
+			if a.minify {
+				content = MinifyCSS(content)
+			}
+		case ".js":
+			if content, err = bundle(fsys, name, jsImportRe, inlineJS); err != nil {
+				return &FileError{File: file, Err: err}
+			}
+			if a.minify {
+				content = MinifyJS(content)
+			}
+		default:
+			if content, err = os.ReadFile(file); err != nil {
+				return err
+			}
+			return a.add(name, file, content, false)
+		}
+		return a.add(name, file, content, true)
+	})
+}
+
+// walkAssets calls fn with the slash-separated name relative to src and
+// the path of every file under src. Hidden files, like .DS_Store, are
+// skipped and a missing src is ignored.
+func walkAssets(src string, fn func(name, file string) error) error {
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.WalkDir(src, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if file != src && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel), file)
+	})
+}
+
+// add adds the asset name with content, read from file. Built assets keep
+// their content to be written, others are copied from file.
+func (a *Assets) add(name, file string, content []byte, built bool) error {
+	if prev, ok := a.assets[name]; ok {
+		return fmt.Errorf("asset %q is both %s and %s", name, prev.src, file)
+	}
+	asset := Asset{Path: path.Join(a.dir, name), Integrity: integrity(content), src: file}
+	if a.fingerprint {
+		asset.Path = path.Join(a.dir, fingerprinted(name, content))
+	}
+	if built {
+		asset.data = append([]byte{}, content...)
+	}
+	a.assets[name] = asset
+	return nil
+}
+
+var (
+	// cssImportRe matches @import "file.css" media; and @import url(file.css);
+	cssImportRe = regexp.MustCompile(`@import\s+(?:url\(\s*)?["']?([^"')\s;]+)["']?\s*\)?([^;]*);`)
+	// jsImportRe matches import "./file.js"; on a line of its own.
+	jsImportRe = regexp.MustCompile(`(?m)^[ \t]*import\s+["']([^"']+)["'][ \t]*;?[ \t]*$`)
+)
+
+// bundle returns the file name of fsys with the local files it imports,
+// matched by importRe, replaced by what inline makes of their content.
+// Every file is included once; imports of URLs are left alone.
+func bundle(fsys fs.FS, name string, importRe *regexp.Regexp, inline func(content []byte, condition string) []byte) ([]byte, error) {
+	seen := map[string]bool{}
+	var load func(name string) ([]byte, error)
+	load = func(name string) ([]byte, error) {
+		seen[name] = true
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		var out bytes.Buffer
+		last := 0
+		for _, m := range importRe.FindAllSubmatchIndex(content, -1) {
+			ref := string(content[m[2]:m[3]])
+			if isAbsURL(ref) || strings.HasPrefix(ref, "//") {
+				continue
+			}
+			imported := path.Join(path.Dir(name), ref)
+			if strings.HasPrefix(ref, "/") {
+				imported = strings.TrimPrefix(ref, "/")
+			}
+			if !fs.ValidPath(imported) {
+				return nil, fmt.Errorf("import %q is outside the assets directory", ref)
+			}
+
+			out.Write(content[last:m[0]])
+			last = m[1]
+			if seen[imported] {
+				continue
+			}
+			inner, err := load(imported)
+			if err != nil {
+				return nil, fmt.Errorf("import %q: %w", ref, err)
+			}
+			condition := ""
+			if len(m) > 4 && m[4] >= 0 {
+				condition = strings.TrimSpace(string(content[m[4]:m[5]]))
+			}
+			out.Write(inline(inner, condition))
+		}
+		out.Write(content[last:])
+		return out.Bytes(), nil
+	}
+	return load(name)
+}
+
+// inlineCSS returns an imported stylesheet, in a media query for imports
+// with a condition.
+func inlineCSS(content []byte, condition string) []byte {
+	if condition == "" {
+		return content
+	}
+	return fmt.Appendf(nil, "@media %s {\n%s\n}", condition, bytes.TrimSpace(content))
+}
+
+// hoistImports moves the imports left in a bundled stylesheet, those of
+// URLs, to its start, as imports after other rules are ignored.
+func hoistImports(css []byte) []byte {
+	var imports []byte
+	rest := cssImportRe.ReplaceAllFunc(css, func(m []byte) []byte {
+		imports = append(append(imports, m...), '\n')
+		return nil
+	})
+	return append(imports, rest...)
+}
+
+// inlineJS returns an imported script, ending it with a semicolon so that
+// the next statement can't continue it.
+func inlineJS(content []byte, _ string) []byte {
+	content = bytes.TrimSpace(content)
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte(";")) && !bytes.HasSuffix(content, []byte("}")) {
+		content = append(content, ';')
+	}
+	return content
+}
+
+// fingerprinted returns name with a hash of content before its extension.
+func fingerprinted(name string, content []byte) string {
+	sum := sha256.Sum256(content)
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hex.EncodeToString(sum[:4]) + ext
+}
+
+// integrity returns the Subresource Integrity hash of content.
+func integrity(content []byte) string {
+	sum := sha512.Sum384(content)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Get returns the asset called name.
+func (a *Assets) Get(name string) (Asset, error) {
+	asset, ok := a.assets[strings.TrimPrefix(name, "/")]
+	if !ok {
+		return Asset{}, fmt.Errorf("no asset %q", name)
+	}
+	return asset, nil
+}
+
+// Hash identifies the published path and content of every asset, so pages
+// linking to them can be rebuilt when they change.
+func (a *Assets) Hash() string {
+	data, _ := json.Marshal(a.assets)
+	return ComputeHash(data)
+}
+
+// Write publishes every asset and the manifest through b. Fingerprinted
+// assets are also published under their own name, so links written by hand,
+// as in Markdown, keep working. Write sets the input "assets" of b, for
+// outputs that link to assets.
+func (a *Assets) Write(b *Builder) error {
+	b.SetInput("assets", a.Hash())
+	for _, name := range slices.Sorted(maps.Keys(a.assets)) {
+		asset := a.assets[name]
+		targets := []string{asset.Path}
+		if plain := path.Join(a.dir, name); plain != asset.Path {
+			targets = append(targets, plain)
+		}
+		for _, target := range targets {
+			var err error
+			if asset.data != nil {
+				err = b.Render(target, []string{"assets"}, func(w io.Writer) error {
+					_, err := w.Write(asset.data)
+					return err
+				})
+			} else {
+				err = b.Copy(asset.src, target)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return b.Render(AssetManifest, []string{"assets"}, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(a.assets)
+	})
+}
+
+// Funcs returns the template functions resolving assets: asset returns the
+// URL an asset is published at and integrity its Subresource Integrity hash.
+func (a *Assets) Funcs() template.FuncMap {
+	return template.FuncMap{
+		"asset": func(name string) (string, error) {
+			asset, err := a.Get(name)
+			if err != nil {
+				return "", err
+			}
+			return a.baseURL + "/" + asset.Path, nil
+		},
+		"integrity": func(name string) (string, error) {
+			asset, err := a.Get(name)
+			return asset.Integrity, err
+		},
+	}
+}
@@ -0,0 +1,202 @@
+package src
+
+import (
+	"encoding/json"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAssets(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "css"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "css", "site.css"), []byte("body{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "LICENSE"), []byte("MIT"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// sha384 of "body{}"
+	const sri = "sha384-myyg/hQ74aSgjBBvVME/QXAXEkT4Y9dHbVQ5C0lIyGpldvNLJV2IWc5ElXbqLi06"
+
+	tests := []struct {
+		name        string
+		fingerprint bool
+		tmpl        string
+		expected    string
+		files       []string
+		wantErr     bool
+	}{
+		{
+			name:     "Plain names",
+			tmpl:     `{{ asset "css/site.css" }} {{ integrity "css/site.css" }}`,
+			expected: "https://example.com/assets/css/site.css " + sri,
+			files:    []string{"css/site.css", "LICENSE"},
+		},
+		{
+			name:        "Fingerprinted names",
+			fingerprint: true,
+			tmpl:        `{{ asset "/css/site.css" }} {{ asset "LICENSE" }}`,
+			expected:    "https://example.com/assets/css/site.7c98040a.css https://example.com/assets/LICENSE.e5dcffe8",
+			files:       []string{"css/site.css", "css/site.7c98040a.css", "LICENSE", "LICENSE.e5dcffe8"},
+		},
+		{
+			name:    "Unknown asset",
+			tmpl:    `{{ asset "css/missing.css" }}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assets := NewAssets("assets", Config{BaseURL: "https://example.com", Assets: AssetsConfig{Fingerprint: tt.fingerprint}})
+			if err := assets.Load(src); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			tmpl := template.Must(template.New(tt.name).Funcs(assets.Funcs()).Parse(tt.tmpl))
+			var out strings.Builder
+			err := tmpl.Execute(&out, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if out.String() != tt.expected {
+				t.Errorf("Execute() = %q, want %q", out.String(), tt.expected)
+			}
+
+			dir := t.TempDir()
+			b := NewBuilder(filepath.Join(dir, "public"), filepath.Join(dir, "manifest.json"))
+			if err := assets.Write(b); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := b.Finish(); err != nil {
+				t.Fatalf("Finish() error = %v", err)
+			}
+			for _, file := range tt.files {
+				if _, err := os.Stat(filepath.Join(dir, "public", "assets", file)); err != nil {
+					t.Errorf("%s was not published: %v", file, err)
+				}
+			}
+
+			data, err := os.ReadFile(filepath.Join(dir, "public", AssetManifest))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var manifest map[string]Asset
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				t.Fatalf("manifest: %v", err)
+			}
+			if got := manifest["css/site.css"]; got.Integrity != sri || !strings.HasPrefix(got.Path, "assets/css/site.") {
+				t.Errorf("manifest entry = %+v", got)
+			}
+		})
+	}
+}
+
+func TestAssetsBuild(t *testing.T) {
+	files := map[string]string{
+		"css/site.css":         "@import \"_base.css\";\n@import url('/css/_print.css') print;\n@import \"https://fonts.example/a.css\";\np { margin: 0 }\n",
+		"css/_base.css":        "body { color: red }\n",
+		"css/_print.css":       "nav { display: none }\n",
+		"js/site.js":           "import \"./_util.js\";\nimport './_util.js';\nutil()\n",
+		"js/_util.js":          "function util() {\n  return 1\n}\n",
+		"_partials/ignored.js": "ignored()\n",
+		"img/logo.svg":         "<svg/>",
+		"bad.css":              "@import \"missing.css\";\n",
+	}
+
+	tests := []struct {
+		name     string
+		minify   bool
+		file     string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "CSS imports",
+			file:     "css/site.css",
+			expected: "@import \"https://fonts.example/a.css\";\nbody { color: red }\n\n@media print {\nnav { display: none }\n}\n\np { margin: 0 }\n",
+		},
+		{
+			name:     "Minified CSS",
+			minify:   true,
+			file:     "css/site.css",
+			expected: "@import \"https://fonts.example/a.css\";body{color:red}@media print{nav{display:none}}p{margin:0}",
+		},
+		{
+			name:     "JS imports",
+			file:     "js/site.js",
+			expected: "function util() {\n  return 1\n}\n\nutil()\n",
+		},
+		{
+			name:     "Minified JS",
+			minify:   true,
+			file:     "js/site.js",
+			expected: "function util(){return 1}\nutil()",
+		},
+		{
+			name:     "Other files",
+			file:     "img/logo.svg",
+			expected: "<svg/>",
+		},
+		{
+			name:    "Missing import",
+			file:    "bad.css",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := t.TempDir()
+			for name, content := range files {
+				if !tt.wantErr && name == "bad.css" {
+					continue
+				}
+				file := filepath.Join(src, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			assets := NewAssets("assets", Config{Minify: MinifyConfig{Assets: tt.minify}})
+			err := assets.Build(src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Build() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if _, err := assets.Get("_partials/ignored.js"); err == nil {
+				t.Errorf("Build() published a file starting with an underscore")
+			}
+
+			dir := t.TempDir()
+			b := NewBuilder(filepath.Join(dir, "public"), filepath.Join(dir, "manifest.json"))
+			if err := assets.Write(b); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := b.Finish(); err != nil {
+				t.Fatalf("Finish() error = %v", err)
+			}
+			got, err := os.ReadFile(filepath.Join(dir, "public", "assets", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("%s = %q, want %q", tt.file, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package src
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/iashyam/gossg/src/parser"
+	"gopkg.in/yaml.v3"
+)
+
+// Author is a writer described in data/authors.yaml.
+type Author struct {
+	ID     string       `yaml:"-"`
+	Name   string       `yaml:"name"`
+	Bio    string       `yaml:"bio"`
+	Avatar string       `yaml:"avatar"`
+	Links  []AuthorLink `yaml:"links"`
+	// Posts lists the author's posts, newest first.
+	Posts []Post `yaml:"-"`
+}
+
+// AuthorLink is a named link on an author's profile, like a homepage or
+// GitHub account.
+type AuthorLink struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+}
+
+// loadAuthors reads the authors file at path. A missing file means the site
+// has no author profiles.
+func (s *Site) loadAuthors(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var authors map[string]*Author
+	if err := yaml.Unmarshal(data, &authors); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for id, author := range authors {
+		if author == nil {
+			author = &Author{}
+			authors[id] = author
+		}
+		author.ID = id
+		if author.Name == "" {
+			author.Name = id
+		}
+		s.Authors[id] = author
+	}
+	return nil
+}
+
+// resolveAuthors returns the authors named by fm, falling back to the
+// configured default authors. Names are matched against author ids first and
+// then against display names; unknown authors get a bare profile.
+func (s *Site) resolveAuthors(fm parser.Frontmatter) []*Author {
+	names := fm.AuthorIDs
+	if len(names) == 0 && fm.Author != "" {
+		names = []string{fm.Author}
+	}
+	if len(names) == 0 {
+		names = s.Config.Authors
+	}
+
+	var authors []*Author
+	for _, name := range names {
+		author := s.findAuthor(name)
+		if author == nil {
+			slog.Warn("unknown author", "author", name)
+			id := Slugify(name)
+			author = &Author{ID: id, Name: name}
+			s.Authors[id] = author
+		}
+		authors = append(authors, author)
+	}
+	return authors
+}
+
+func (s *Site) findAuthor(name string) *Author {
+	if author, ok := s.Authors[name]; ok {
+		return author
+	}
+	ids := make([]string, 0, len(s.Authors))
+	for id := range s.Authors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids) // the same name always resolves the same way
+	for _, id := range ids {
+		if strings.EqualFold(s.Authors[id].Name, name) {
+			return s.Authors[id]
+		}
+	}
+	return nil
+}
+
+// structuredData describes a page as schema.org JSON-LD.
+func structuredData(kind string, fm parser.Frontmatter, authors []*Author) map[string]any {
+	data := map[string]any{
+		"@context": "https://schema.org",
+		"@type":    kind,
+		"headline": fm.Title,
+	}
+	if fm.Date != "" {
+		data["datePublished"] = fm.Date
+	}
+	if fm.Description != "" {
+		data["description"] = fm.Description
+	}
+	if len(fm.Tags) > 0 {
+		data["keywords"] = strings.Join(fm.Tags, ", ")
+	}
+	var people []map[string]any
+	for _, author := range authors {
+		person := map[string]any{"@type": "Person", "name": author.Name}
+		var sameAs []string
+		for _, link := range author.Links {
+			sameAs = append(sameAs, link.URL)
+		}
+		if len(sameAs) > 0 {
+			person["sameAs"] = sameAs
+		}
+		people = append(people, person)
+	}
+	if len(people) > 0 {
+		data["author"] = people
+	}
+	return data
+}
+
+// StructuredData returns the post's schema.org metadata for a JSON-LD script.
+func (p Post) StructuredData() map[string]any {
+	return structuredData("BlogPosting", p.Frontmatter, p.Authors)
+}
+
+// StructuredData returns the page's schema.org metadata for a JSON-LD script.
+func (p Page) StructuredData() map[string]any {
+	return structuredData("WebPage", p.Frontmatter, p.Authors)
+}
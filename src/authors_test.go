@@ -0,0 +1,66 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iashyam/gossg/src/parser"
+)
+
+func TestResolveAuthors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "authors.yaml")
+	data := "ada:\n  name: Ada Lovelace\nalan:\n  name: Alan Turing\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		fm       parser.Frontmatter
+		defaults []string
+		expected []string // author ids
+	}{
+		{
+			name:     "Ids from the authors list",
+			fm:       parser.Frontmatter{AuthorIDs: []string{"alan", "ada"}},
+			expected: []string{"alan", "ada"},
+		},
+		{
+			name:     "Single author by display name",
+			fm:       parser.Frontmatter{Author: "ada lovelace"},
+			expected: []string{"ada"},
+		},
+		{
+			name:     "Configured defaults",
+			defaults: []string{"ada"},
+			expected: []string{"ada"},
+		},
+		{
+			name:     "Unknown author",
+			fm:       parser.Frontmatter{AuthorIDs: []string{"Grace Hopper"}},
+			expected: []string{"grace-hopper"},
+		},
+		{
+			name: "No authors",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := NewSite(Config{Authors: tt.defaults})
+			if err := site.loadAuthors(path); err != nil {
+				t.Fatalf("loadAuthors() error = %v", err)
+			}
+			authors := site.resolveAuthors(tt.fm)
+			if len(authors) != len(tt.expected) {
+				t.Fatalf("resolveAuthors() returned %d authors, want %d", len(authors), len(tt.expected))
+			}
+			for i, author := range authors {
+				if author.ID != tt.expected[i] {
+					t.Errorf("author %d = %q, want %q", i, author.ID, tt.expected[i])
+				}
+			}
+		})
+	}
+}
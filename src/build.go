@@ -0,0 +1,387 @@
+package src
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Manifest records, for every output file of a build, the hash of what was
+// written and the hashes of the inputs it was rendered from.
+type Manifest struct {
+	Outputs map[string]OutputRecord `json:"outputs"`
+}
+
+// OutputRecord describes one output file in the Manifest.
+type OutputRecord struct {
+	Hash   string            `json:"hash"`
+	Inputs map[string]string `json:"inputs"`
+}
+
+// Builder writes a build's output files. An output is only rendered again
+// when one of its inputs changed, is only written when its content changed,
+// and outputs that a build no longer produces are removed. Rendered outputs
+// are kept in memory until Finish writes them, so the output directory is
+// left alone until the whole build has succeeded.
+//
+// Inputs are file paths, hashed from disk, or names set with SetInput.
+type Builder struct {
+	dir          string
+	manifestPath string
+	prev         Manifest
+	// mu guards next, hashes, pending and the counts, as outputs are
+	// rendered concurrently.
+	mu      sync.Mutex
+	next    Manifest
+	hashes  map[string]string
+	pending map[string][]byte
+
+	Written, Unchanged, Removed int
+	// BytesWritten is the size of the files written.
+	BytesWritten int64
+}
+
+// NewBuilder returns a Builder writing into dir, using the manifest of the
+// previous build at manifestPath.
+func NewBuilder(dir, manifestPath string) *Builder {
+	b := &Builder{
+		dir:          dir,
+		manifestPath: manifestPath,
+		next:         Manifest{Outputs: map[string]OutputRecord{}},
+		hashes:       map[string]string{},
+		pending:      map[string][]byte{},
+	}
+	if data, err := os.ReadFile(manifestPath); err == nil {
+		if err := json.Unmarshal(data, &b.prev); err != nil {
+			slog.Warn("ignoring unreadable build manifest", "file", manifestPath, "err", err)
+			b.prev = Manifest{}
+		}
+	}
+	return b
+}
+
+// SetInput sets the hash of a named input that isn't a single file, like
+// the theme or the list of all posts.
+func (b *Builder) SetInput(name, hash string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.hashes[name] = hash
+}
+
+// inputHash returns the hash of the named input. b.mu must be held.
+func (b *Builder) inputHash(name string) string {
+	if hash, ok := b.hashes[name]; ok {
+		return hash
+	}
+	hash := "missing"
+	if content, err := os.ReadFile(name); err == nil {
+		hash = ComputeHash(content)
+	}
+	b.hashes[name] = hash
+	return hash
+}
+
+// Render produces the output file at path, relative to the output
+// directory, by calling render, unless the output was built from the same
+// inputs before and is still intact. The file is written by Finish.
+func (b *Builder) Render(path string, inputs []string, render func(w io.Writer) error) error {
+	record := OutputRecord{Inputs: make(map[string]string, len(inputs))}
+	b.mu.Lock()
+	for _, input := range inputs {
+		record.Inputs[input] = b.inputHash(input)
+	}
+	b.mu.Unlock()
+
+	target := filepath.Join(b.dir, path)
+	if prev, ok := b.prev.Outputs[path]; ok && sameInputs(prev.Inputs, record.Inputs) {
+		if content, err := os.ReadFile(target); err == nil && ComputeHash(content) == prev.Hash {
+			b.mu.Lock()
+			b.next.Outputs[path] = prev
+			b.Unchanged++
+			b.mu.Unlock()
+			return nil
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := render(&buf); err != nil {
+		return err
+	}
+	record.Hash = ComputeHash(buf.Bytes())
+	b.mu.Lock()
+	b.next.Outputs[path] = record
+	b.pending[path] = buf.Bytes()
+	b.mu.Unlock()
+	return nil
+}
+
+// Copy copies the file src to path, relative to the output directory.
+func (b *Builder) Copy(src, path string) error {
+	return b.Render(path, []string{src}, func(w io.Writer) error {
+		in, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		_, err = io.Copy(w, in)
+		return err
+	})
+}
+
+// writeIfChanged writes data to target unless it already holds data, so
+// unchanged files keep their modification time. It reports whether the file
+// was written. A changed target is replaced rather than written to, so other
+// links to it keep their content.
+func writeIfChanged(target string, data []byte) (bool, error) {
+	if existing, err := os.ReadFile(target); err == nil && bytes.Equal(existing, data) {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return false, err
+	}
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if err := os.WriteFile(target, data, 0644); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Finish removes the outputs of the previous build that this build didn't
+// produce, writes the rendered outputs and saves the manifest. Without a
+// previous manifest, every unknown file in the output directory is removed,
+// except hidden ones.
+//
+// The new output directory is assembled next to the old one, from hard
+// links to the files that stay and the files that changed, and only
+// replaces it once complete, so a failed build leaves the previous output
+// intact.
+func (b *Builder) Finish() error {
+	orphans, err := b.orphans()
+	if err != nil {
+		return err
+	}
+
+	staging := b.sibling("staging")
+	if err := os.RemoveAll(staging); err != nil {
+		return err
+	}
+	if err := b.stage(staging, orphans); err != nil {
+		os.RemoveAll(staging)
+		return err
+	}
+	if err := swapDir(staging, b.dir, b.sibling("old")); err != nil {
+		os.RemoveAll(staging)
+		return fmt.Errorf("failed to replace %s: %w", b.dir, err)
+	}
+	b.Removed = len(orphans)
+
+	data, err := json.MarshalIndent(b.next, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal build manifest: %w", err)
+	}
+	if err := writeFileAtomic(b.manifestPath, data); err != nil {
+		return fmt.Errorf("failed to write build manifest: %w", err)
+	}
+	return nil
+}
+
+// orphans returns the files in the output directory that this build no
+// longer produces.
+func (b *Builder) orphans() (map[string]bool, error) {
+	orphans := map[string]bool{}
+	if b.prev.Outputs != nil {
+		for path := range b.prev.Outputs {
+			if _, ok := b.next.Outputs[path]; !ok {
+				orphans[path] = true
+			}
+		}
+		return orphans, nil
+	}
+
+	err := filepath.WalkDir(b.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != b.dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(b.dir, path)
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if _, ok := b.next.Outputs[rel]; !ok {
+			orphans[rel] = true
+		}
+		return nil
+	})
+	return orphans, err
+}
+
+// sibling returns the path of a temporary directory next to the output
+// directory, on the same file system so that it can be renamed into place.
+func (b *Builder) sibling(kind string) string {
+	return filepath.Join(filepath.Dir(b.dir), "."+filepath.Base(b.dir)+"."+kind)
+}
+
+// stage fills staging with the current output directory, minus orphans,
+// and the rendered outputs.
+func (b *Builder) stage(staging string, orphans map[string]bool) error {
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		return err
+	}
+	err := filepath.WalkDir(b.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(b.dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		target := filepath.Join(staging, rel)
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, 0755)
+		case orphans[rel]:
+			return nil
+		default:
+			return linkFile(path, target)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	for path := range orphans {
+		removeEmptyDirs(filepath.Dir(filepath.Join(staging, path)), staging)
+	}
+
+	var errs []error
+	for _, path := range slices.Sorted(maps.Keys(b.pending)) {
+		data := b.pending[path]
+		written, err := writeIfChanged(filepath.Join(staging, path), data)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("failed to write %s: %w", path, err))
+		case written:
+			b.Written++
+			b.BytesWritten += int64(len(data))
+		default:
+			b.Unchanged++
+		}
+	}
+	b.pending = map[string][]byte{}
+	return errors.Join(errs...)
+}
+
+// linkFile makes target the same file as src, keeping its modification
+// time. It copies src when it can't be hard linked.
+func linkFile(src, target string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		dest, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(dest, target)
+	}
+	if err := os.Link(src, target); err == nil {
+		return nil
+	}
+
+	content, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(target, content, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(target, info.ModTime(), info.ModTime())
+}
+
+// swapDir moves dir to old and staging to dir, then removes old. If staging
+// can't be moved, dir is put back.
+func swapDir(staging, dir, old string) error {
+	if err := os.RemoveAll(old); err != nil {
+		return err
+	}
+	if err := os.Rename(dir, old); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(staging, dir); err != nil {
+		os.Rename(old, dir)
+		return err
+	}
+	return os.RemoveAll(old)
+}
+
+// removeEmptyDirs removes dir and its parents up to, not including, root
+// while they are empty.
+func removeEmptyDirs(dir, root string) {
+	for dir != root && strings.HasPrefix(dir, root) {
+		if os.Remove(dir) != nil {
+			return // not empty
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func sameInputs(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, hash := range a {
+		if b[name] != hash {
+			return false
+		}
+	}
+	return true
+}
+
+// HashFS returns a hash of every file under dir of fsys, for use as an
+// input standing for a whole directory. A missing dir hashes to "missing".
+func HashFS(fsys fs.FS, dir string) string {
+	h := sha256.New()
+	found := false
+	fs.WalkDir(fsys, dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		content, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return nil
+		}
+		found = true
+		fmt.Fprintf(h, "%s\x00%d\x00", path, len(content))
+		h.Write(content)
+		return nil
+	})
+	if !found {
+		return "missing"
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,154 @@
+package src
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "public")
+	manifest := filepath.Join(dir, "manifest.json")
+	input := filepath.Join(dir, "post.md")
+
+	renders := 0
+	build := func(outputs map[string]string) *Builder {
+		t.Helper()
+		b := NewBuilder(out, manifest)
+		for path, content := range outputs {
+			err := b.Render(path, []string{input}, func(w io.Writer) error {
+				renders++
+				_, err := io.WriteString(w, content)
+				return err
+			})
+			if err != nil {
+				t.Fatalf("Render(%s) error = %v", path, err)
+			}
+		}
+		if err := b.Finish(); err != nil {
+			t.Fatalf("Finish() error = %v", err)
+		}
+		return b
+	}
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(input, "v1")
+	write(filepath.Join(out, "old", "stale.html"), "stale")
+	write(filepath.Join(out, ".git", "HEAD"), "ref")
+
+	steps := []struct {
+		name      string
+		input     string // new content of the input, if any
+		outputs   map[string]string
+		renders   int
+		written   int
+		removed   int
+		exists    []string
+		notExists []string
+	}{
+		{
+			name:      "First build removes unknown files",
+			outputs:   map[string]string{"index.html": "home", "posts/a.html": "a"},
+			renders:   2,
+			written:   2,
+			removed:   1,
+			exists:    []string{"index.html", "posts/a.html", ".git/HEAD"},
+			notExists: []string{"old"},
+		},
+		{
+			name:    "Unchanged inputs skip rendering",
+			outputs: map[string]string{"index.html": "home", "posts/a.html": "a"},
+		},
+		{
+			name:    "Changed input with the same output is not rewritten",
+			input:   "v2",
+			outputs: map[string]string{"index.html": "home", "posts/a.html": "a"},
+			renders: 2,
+		},
+		{
+			name:      "Outputs no longer produced are removed",
+			outputs:   map[string]string{"index.html": "home"},
+			removed:   1,
+			notExists: []string{"posts"},
+		},
+	}
+
+	for _, step := range steps {
+		if step.input != "" {
+			write(input, step.input)
+		}
+		renders = 0
+		b := build(step.outputs)
+		if renders != step.renders || b.Written != step.written || b.Removed != step.removed {
+			t.Errorf("%s: renders, written, removed = %d, %d, %d, want %d, %d, %d", step.name,
+				renders, b.Written, b.Removed, step.renders, step.written, step.removed)
+		}
+		for _, path := range step.exists {
+			if _, err := os.Stat(filepath.Join(out, path)); err != nil {
+				t.Errorf("%s: %s should exist: %v", step.name, path, err)
+			}
+		}
+		for _, path := range step.notExists {
+			if _, err := os.Stat(filepath.Join(out, path)); !os.IsNotExist(err) {
+				t.Errorf("%s: %s should not exist", step.name, path)
+			}
+		}
+	}
+}
+
+func TestBuilderFailedFinish(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "public")
+	manifest := filepath.Join(dir, "manifest.json")
+
+	build := func(outputs map[string]string) error {
+		b := NewBuilder(out, manifest)
+		for path, content := range outputs {
+			err := b.Render(path, nil, func(w io.Writer) error {
+				_, err := io.WriteString(w, content)
+				return err
+			})
+			if err != nil {
+				t.Fatalf("Render(%s) error = %v", path, err)
+			}
+		}
+		return b.Finish()
+	}
+
+	if err := build(map[string]string{"index.html": "v1", "about.html": "about"}); err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+
+	// index.html is still a file, so nothing can be written under it
+	err := build(map[string]string{"index.html": "v2", "index.html/page.html": "page"})
+	if err == nil {
+		t.Fatalf("Finish() error = nil, want an error")
+	}
+
+	content, err := os.ReadFile(filepath.Join(out, "index.html"))
+	if err != nil || string(content) != "v1" {
+		t.Errorf("index.html = %q, %v, want the previous build's v1", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "about.html")); err != nil {
+		t.Errorf("about.html of the previous build was removed: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Name() != "public" && entry.Name() != "manifest.json" {
+			t.Errorf("Finish() left %s behind", entry.Name())
+		}
+	}
+}
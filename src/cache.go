@@ -3,63 +3,258 @@ package src
 import (
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"sync"
 
 	"github.com/iashyam/gossg/src/parser"
 )
 
 // CachedFile represents the parsed data and hash of a single markdown file
 type CachedFile struct {
-	Hash        string             `json:"hash"`
-	Frontmatter parser.Frontmatter `json:"frontmatter"`
-	ContentHTML string             `json:"content_html"`
+	Hash            string             `json:"hash"`
+	Frontmatter     parser.Frontmatter `json:"frontmatter"`
+	ContentHTML     string             `json:"content_html"`
+	TableOfContents string             `json:"table_of_contents,omitempty"`
+	Headings        []Heading          `json:"headings,omitempty"`
+	WordCount       int                `json:"word_count"`
+	ReadingTime     int                `json:"reading_time"`
+	// Includes holds the hash of every file the include shortcode read, by
+	// path. The entry is stale when any of them changes.
+	Includes map[string]string `json:"includes,omitempty"`
 }
 
+// CacheVersion is the layout of the cache file. Bump it whenever CachedFile
+// changes so that caches written by older builds are discarded.
+const CacheVersion = 3
+
 // Cache manages the state of all processed files
 type Cache struct {
-	cachePath string
-	Files     map[string]CachedFile `json:"files"`
+	store CacheStore
+	// Fingerprint identifies the renderer and settings the entries were
+	// rendered with. A cache saved with another fingerprint is discarded.
+	Fingerprint string
+	Files       map[string]CachedFile `json:"files"`
+	// used holds the paths looked up or stored during this build.
+	used map[string]bool
+	// mu guards Files, used and the counts, which files loaded at the same
+	// time share.
+	mu sync.Mutex
+
+	// Hits and Misses count the lookups of this build.
+	Hits, Misses int
+}
+
+// errCorruptCache reports a cache that can't be parsed, such as one left
+// truncated by an interrupted build.
+var errCorruptCache = errors.New("cache is corrupt")
+
+// CacheData is the content of a cache as kept by a CacheStore.
+type CacheData struct {
+	Version     int                   `json:"version"`
+	Fingerprint string                `json:"fingerprint"`
+	Files       map[string]CachedFile `json:"files"`
 }
 
-// NewCache initializes a new Cache instance
-func NewCache(cachePath string) *Cache {
+// NewCache initializes a new Cache instance kept in store
+func NewCache(store CacheStore) *Cache {
 	return &Cache{
-		cachePath: cachePath,
-		Files:     make(map[string]CachedFile),
+		store: store,
+		Files: make(map[string]CachedFile),
+		used:  make(map[string]bool),
 	}
 }
 
-// Load attempts to read the cache from disk
-func (c *Cache) Load() error {
-	data, err := os.ReadFile(c.cachePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // No cache file yet, which is fine
+// Get returns the entry for path if it was rendered from content with the
+// given hash and the files it includes haven't changed.
+func (c *Cache) Get(path, hash string) (CachedFile, bool) {
+	c.mu.Lock()
+	c.used[path] = true
+	file, ok := c.Files[path]
+	c.mu.Unlock()
+	hit := ok && file.Hash == hash && file.includesUnchanged()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !hit {
+		c.Misses++
+		return CachedFile{}, false
+	}
+	c.Hits++
+	return file, true
+}
+
+// Put stores the entry for path.
+func (c *Cache) Put(path string, file CachedFile) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.used[path] = true
+	c.Files[path] = file
+}
+
+// RemoveUnused removes the entries that weren't looked up or stored since
+// the cache was created, such as those of deleted or renamed files. It
+// returns the number of entries removed.
+func (c *Cache) RemoveUnused() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for path := range c.Files {
+		if !c.used[path] {
+			delete(c.Files, path)
+			removed++
 		}
-		return fmt.Errorf("failed to read cache: %w", err)
 	}
+	return removed
+}
+
+// current reports whether the entries of f were rendered the way c would
+// render them.
+func (c *Cache) current(f CacheData) bool {
+	return f.Version == CacheVersion && f.Fingerprint == c.Fingerprint
+}
 
-	if err := json.Unmarshal(data, &c.Files); err != nil {
-		return fmt.Errorf("failed to parse cache: %w", err)
+// Load attempts to read the cache from disk. Entries rendered by another
+// version of gossg or with other settings are discarded, and a corrupt cache
+// is treated as empty.
+func (c *Cache) Load() error {
+	f, err := c.store.Load()
+	if errors.Is(err, errCorruptCache) {
+		slog.Warn("ignoring corrupt cache, rendering everything again", "cache", c.store.Location(), "err", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(f.Files) > 0 && !c.current(f) {
+		slog.Info("cache was rendered by another gossg version or config, discarding it", "cache", c.store.Location())
+		return nil
+	}
+	for path, file := range f.Files {
+		c.Files[path] = file
 	}
 	return nil
 }
 
 // Save writes the current cache state to disk
 func (c *Cache) Save() error {
-	data, err := json.MarshalIndent(c.Files, "", "  ")
+	return c.store.Save(CacheData{
+		Version:     CacheVersion,
+		Fingerprint: c.Fingerprint,
+		Files:       c.Files,
+	})
+}
+
+// writeFileAtomic replaces the file at path with data. The data is written
+// to a temporary file first, so an interrupted write never leaves a
+// truncated file behind.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*.tmp")
 	if err != nil {
-		return fmt.Errorf("failed to marshal cache: %w", err)
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
 	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
 
-	if err := os.WriteFile(c.cachePath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write cache: %w", err)
+// Clear deletes the cache file.
+func (c *Cache) Clear() error {
+	c.Files = make(map[string]CachedFile)
+	if err := c.store.Clear(); err != nil {
+		return fmt.Errorf("failed to remove cache: %w", err)
 	}
 	return nil
 }
 
+// CacheStats describes the cache file on disk.
+type CacheStats struct {
+	Path    string
+	Bytes   int64
+	Version int
+	// Current reports whether the entries can be used by this build.
+	Current bool
+	Entries int
+	// Stale counts the entries whose file was changed or removed.
+	Stale int
+}
+
+// Stats reads the cache file without discarding anything.
+func (c *Cache) Stats() (CacheStats, error) {
+	stats := CacheStats{Path: c.store.Location(), Bytes: c.store.Size()}
+	f, err := c.store.Load()
+	if err != nil {
+		return stats, err
+	}
+	stats.Version = f.Version
+	stats.Current = c.current(f)
+	stats.Entries = len(f.Files)
+	for path, file := range f.Files {
+		if !upToDate(path, file) {
+			stats.Stale++
+		}
+	}
+	return stats, nil
+}
+
+// Prune removes the entries that can't be used any more: all of them when
+// they were rendered by another version or config or the cache is corrupt,
+// otherwise those whose file was changed or removed. It returns the number of entries removed.
+func (c *Cache) Prune() (int, error) {
+	f, err := c.store.Load()
+	if errors.Is(err, errCorruptCache) {
+		slog.Warn("emptying corrupt cache", "cache", c.store.Location(), "err", err)
+	} else if err != nil {
+		return 0, err
+	}
+	c.Files = make(map[string]CachedFile)
+	removed := 0
+	for path, file := range f.Files {
+		if !c.current(f) || !upToDate(path, file) {
+			removed++
+			continue
+		}
+		c.Files[path] = file
+	}
+	return removed, c.Save()
+}
+
+// upToDate reports whether the file at path, and the files it includes,
+// still have the content file was rendered from.
+func upToDate(path string, file CachedFile) bool {
+	content, err := os.ReadFile(path)
+	return err == nil && ComputeHash(content) == file.Hash && file.includesUnchanged()
+}
+
+// includesUnchanged reports whether the files included by the shortcodes
+// of file still have the content they had when it was rendered.
+func (file CachedFile) includesUnchanged() bool {
+	for path, hash := range file.Includes {
+		content, err := os.ReadFile(path)
+		if err != nil || ComputeHash(content) != hash {
+			return false
+		}
+	}
+	return true
+}
+
 // ComputeHash calculates the SHA-256 hash of the given content
 func ComputeHash(content []byte) string {
 	hash := sha256.Sum256(content)
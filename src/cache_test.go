@@ -0,0 +1,211 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCacheLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		saved   string // cache file content, empty for none
+		entries int
+	}{
+		{
+			name:    "No cache file",
+			entries: 0,
+		},
+		{
+			name:    "Same version and fingerprint",
+			saved:   `{"version": 3, "fingerprint": "abc", "files": {"a.md": {"hash": "1"}}}`,
+			entries: 1,
+		},
+		{
+			name:    "Other fingerprint",
+			saved:   `{"version": 3, "fingerprint": "def", "files": {"a.md": {"hash": "1"}}}`,
+			entries: 0,
+		},
+		{
+			name:    "Older version",
+			saved:   `{"version": 2, "fingerprint": "abc", "files": {"a.md": {"hash": "1"}}}`,
+			entries: 0,
+		},
+		{
+			name:    "Truncated cache",
+			saved:   `{"version": 3, "fingerprint": "abc", "files": {"a.md": {"ha`,
+			entries: 0,
+		},
+		{
+			name:    "Cache without a header",
+			saved:   `{"a.md": {"hash": "1"}}`,
+			entries: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cache.json")
+			if tt.saved != "" {
+				if err := os.WriteFile(path, []byte(tt.saved), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c := NewCache(NewJSONStore(path))
+			c.Fingerprint = "abc"
+			if err := c.Load(); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if len(c.Files) != tt.entries {
+				t.Errorf("Load() kept %d entries, want %d", len(c.Files), tt.entries)
+			}
+		})
+	}
+}
+
+func TestCacheIncludes(t *testing.T) {
+	include := filepath.Join(t.TempDir(), "x.py")
+	if err := os.WriteFile(include, []byte("print(1)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCache(NewJSONStore(filepath.Join(t.TempDir(), "cache.json")))
+	c.Put("a.md", CachedFile{Hash: "1", Includes: map[string]string{include: ComputeHash([]byte("print(1)"))}})
+	if _, hit := c.Get("a.md", "1"); !hit {
+		t.Errorf("Get() missed with unchanged includes")
+	}
+
+	if err := os.WriteFile(include, []byte("print(2)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, hit := c.Get("a.md", "1"); hit {
+		t.Errorf("Get() hit after an included file changed")
+	}
+
+	if err := os.Remove(include); err != nil {
+		t.Fatal(err)
+	}
+	if _, hit := c.Get("a.md", "1"); hit {
+		t.Errorf("Get() hit after an included file was removed")
+	}
+}
+
+func TestCachePrune(t *testing.T) {
+	dir := t.TempDir()
+	kept := filepath.Join(dir, "kept.md")
+	changed := filepath.Join(dir, "changed.md")
+	if err := os.WriteFile(kept, []byte("kept"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(changed, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCache(NewJSONStore(filepath.Join(dir, "cache.json")))
+	c.Fingerprint = "abc"
+	c.Files[kept] = CachedFile{Hash: ComputeHash([]byte("kept"))}
+	c.Files[changed] = CachedFile{Hash: ComputeHash([]byte("old"))}
+	c.Files[filepath.Join(dir, "removed.md")] = CachedFile{Hash: "1"}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+	if !stats.Current || stats.Entries != 3 || stats.Stale != 2 {
+		t.Errorf("Stats() = %+v, want 3 current entries with 2 stale", stats)
+	}
+
+	removed, err := c.Prune()
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Prune() removed %d entries, want 2", removed)
+	}
+
+	c = NewCache(c.store)
+	c.Fingerprint = "abc"
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Files[kept]; !ok || len(c.Files) != 1 {
+		t.Errorf("after Prune() the cache holds %v, want only %s", c.Files, kept)
+	}
+}
+
+func TestCacheRemoveUnused(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.json")
+
+	c := NewCache(NewJSONStore(path))
+	c.Put("kept.md", CachedFile{Hash: "1"})
+	c.Put("renamed.md", CachedFile{Hash: "2"})
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next build only reads kept.md and a new file
+	c = NewCache(NewJSONStore(path))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, hit := c.Get("kept.md", "1"); !hit {
+		t.Errorf("Get(kept.md) missed, want a hit")
+	}
+	if _, hit := c.Get("new.md", "3"); hit {
+		t.Errorf("Get(new.md) hit, want a miss")
+	}
+	c.Put("new.md", CachedFile{Hash: "3"})
+
+	if removed := c.RemoveUnused(); removed != 1 {
+		t.Errorf("RemoveUnused() = %d, want 1", removed)
+	}
+	if _, ok := c.Files["renamed.md"]; ok || len(c.Files) != 2 {
+		t.Errorf("after RemoveUnused() the cache holds %v, want kept.md and new.md", c.Files)
+	}
+
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Save() left %d files in the cache directory, want only the cache", len(entries))
+	}
+}
+
+func TestCacheFingerprint(t *testing.T) {
+	partials := func(content string) *Partials {
+		p, err := LoadPartials(fstest.MapFS{"partials/card.html": {Data: []byte(content)}}, "partials", filepath.Join(t.TempDir(), "missing"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	base := &Site{Config: Config{SiteName: "Blog"}, Partials: partials("a")}
+	want := base.CacheFingerprint()
+
+	tests := []struct {
+		name string
+		site *Site
+		same bool
+	}{
+		{name: "Same settings", site: &Site{Config: Config{SiteName: "Blog"}, Partials: partials("a")}, same: true},
+		{name: "Site name", site: &Site{Config: Config{SiteName: "Notes"}, Partials: partials("a")}},
+		{name: "Partials", site: &Site{Config: Config{SiteName: "Blog"}, Partials: partials("b")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.site.CacheFingerprint(); (got == want) != tt.same {
+				t.Errorf("CacheFingerprint() = %s, base %s, want same %v", got, want, tt.same)
+			}
+		})
+	}
+}
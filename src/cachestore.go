@@ -0,0 +1,448 @@
+package src
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	bolterrors "go.etcd.io/bbolt/errors"
+)
+
+// CacheStore keeps the cache between builds.
+type CacheStore interface {
+	// Load returns the stored cache, which is empty if nothing was saved
+	// yet. A cache that can't be parsed gives an error wrapping
+	// errCorruptCache.
+	Load() (CacheData, error)
+	// Save replaces the stored cache with data.
+	Save(data CacheData) error
+	// Clear deletes the stored cache.
+	Clear() error
+	// Location is the file or directory the cache is kept in, and Size the
+	// number of bytes it takes up.
+	Location() string
+	Size() int64
+}
+
+// OpenCacheStore returns the store selected by cfg. The GOSSG_CACHE_DIR
+// environment variable overrides the directory it is kept in, so CI can
+// point it at a directory it saves between runs.
+func OpenCacheStore(cfg CacheConfig) (CacheStore, error) {
+	dir := cfg.Dir
+	if env := os.Getenv("GOSSG_CACHE_DIR"); env != "" {
+		dir = env
+	}
+	// Without a directory the cache sits in the site root, as it always has
+	name := func(inRoot, inDir string) string {
+		if dir == "" {
+			return inRoot
+		}
+		return filepath.Join(dir, inDir)
+	}
+
+	switch cfg.Store {
+	case "", "json":
+		return NewJSONStore(name(".gossg_cache.json", "gossg_cache.json")), nil
+	case "dir":
+		return NewDirStore(name(".gossg_cache", "gossg_cache")), nil
+	case "bolt":
+		return NewBoltStore(name(".gossg_cache.db", "gossg_cache.db")), nil
+	default:
+		return nil, fmt.Errorf("unknown cache store %q, want json, dir or bolt", cfg.Store)
+	}
+}
+
+// mkdirFor creates the directory holding path.
+func mkdirFor(path string) error {
+	return os.MkdirAll(filepath.Dir(path), 0755)
+}
+
+// fileSize returns the size of the file at path, or 0 if there is none.
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// JSONStore keeps the whole cache in a single JSON file.
+type JSONStore struct {
+	path string
+}
+
+// NewJSONStore returns a store keeping the cache in the JSON file at path.
+func NewJSONStore(path string) *JSONStore {
+	return &JSONStore{path: path}
+}
+
+func (s *JSONStore) Load() (CacheData, error) {
+	var data CacheData
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return data, nil // No cache file yet, which is fine
+		}
+		return data, fmt.Errorf("failed to read cache: %w", err)
+	}
+
+	// Caches from before the header was added are a bare map of files,
+	// which leaves the version at 0.
+	if err := json.Unmarshal(content, &data); err != nil {
+		return CacheData{}, fmt.Errorf("%w: %v", errCorruptCache, err)
+	}
+	return data, nil
+}
+
+func (s *JSONStore) Save(data CacheData) error {
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache: %w", err)
+	}
+	if err := mkdirFor(s.path); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path, content); err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	return nil
+}
+
+func (s *JSONStore) Clear() error {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *JSONStore) Location() string { return s.path }
+
+func (s *JSONStore) Size() int64 { return fileSize(s.path) }
+
+// DirStore keeps every entry in its own gzipped file named after the hash
+// of the content it was rendered from, with an index mapping paths to
+// hashes. Saving only writes the entries that changed, and files with the
+// same content share an entry.
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore returns a store keeping the cache in dir.
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{dir: dir}
+}
+
+// dirIndex is the layout of a DirStore's index.json.
+type dirIndex struct {
+	Version     int               `json:"version"`
+	Fingerprint string            `json:"fingerprint"`
+	Files       map[string]string `json:"files"`
+}
+
+func (s *DirStore) indexPath() string {
+	return filepath.Join(s.dir, "index.json")
+}
+
+// blobPath returns the file holding the entry rendered from content with
+// the given hash, spread over subdirectories by its first two characters.
+func (s *DirStore) blobPath(hash string) string {
+	if len(hash) < 2 {
+		return filepath.Join(s.dir, "blobs", hash+".json.gz")
+	}
+	return filepath.Join(s.dir, "blobs", hash[:2], hash+".json.gz")
+}
+
+func (s *DirStore) readIndex() (dirIndex, error) {
+	var index dirIndex
+	content, err := os.ReadFile(s.indexPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return index, nil
+		}
+		return index, fmt.Errorf("failed to read cache index: %w", err)
+	}
+	if err := json.Unmarshal(content, &index); err != nil {
+		return dirIndex{}, fmt.Errorf("%w: %v", errCorruptCache, err)
+	}
+	return index, nil
+}
+
+func (s *DirStore) Load() (CacheData, error) {
+	index, err := s.readIndex()
+	if err != nil {
+		return CacheData{}, err
+	}
+	data := CacheData{
+		Version:     index.Version,
+		Fingerprint: index.Fingerprint,
+		Files:       make(map[string]CachedFile, len(index.Files)),
+	}
+	for path, hash := range index.Files {
+		file, err := s.readBlob(hash)
+		if err != nil {
+			// A missing or damaged entry only costs rendering its file again
+			continue
+		}
+		data.Files[path] = file
+	}
+	return data, nil
+}
+
+func (s *DirStore) readBlob(hash string) (CachedFile, error) {
+	var file CachedFile
+	f, err := os.Open(s.blobPath(hash))
+	if err != nil {
+		return file, err
+	}
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		return file, err
+	}
+	defer r.Close()
+	err = json.NewDecoder(r).Decode(&file)
+	return file, err
+}
+
+func (s *DirStore) writeBlob(file CachedFile) error {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(w).Encode(file); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	path := s.blobPath(file.Hash)
+	if err := mkdirFor(path); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf.Bytes())
+}
+
+func (s *DirStore) Save(data CacheData) error {
+	old, err := s.readIndex()
+	if err != nil && !errors.Is(err, errCorruptCache) {
+		return err
+	}
+	// Entries rendered another way can't be reused under the same hash
+	rewrite := old.Version != data.Version || old.Fingerprint != data.Fingerprint
+
+	index := dirIndex{
+		Version:     data.Version,
+		Fingerprint: data.Fingerprint,
+		Files:       make(map[string]string, len(data.Files)),
+	}
+	blobs := make(map[string]bool)
+	for path, file := range data.Files {
+		index.Files[path] = file.Hash
+		if blobs[file.Hash] {
+			continue
+		}
+		blobs[file.Hash] = true
+		if _, err := os.Stat(s.blobPath(file.Hash)); err == nil && !rewrite {
+			continue
+		}
+		if err := s.writeBlob(file); err != nil {
+			return fmt.Errorf("failed to write cache entry for %s: %w", path, err)
+		}
+	}
+
+	content, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache index: %w", err)
+	}
+	if err := mkdirFor(s.indexPath()); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.indexPath(), content); err != nil {
+		return fmt.Errorf("failed to write cache index: %w", err)
+	}
+
+	// Remove the entries no file refers to any more
+	blobDir := filepath.Join(s.dir, "blobs")
+	return filepath.WalkDir(blobDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if hash := strings.TrimSuffix(d.Name(), ".json.gz"); !blobs[hash] {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			removeEmptyDirs(filepath.Dir(path), blobDir)
+		}
+		return nil
+	})
+}
+
+func (s *DirStore) Clear() error {
+	return os.RemoveAll(s.dir)
+}
+
+func (s *DirStore) Location() string { return s.dir }
+
+func (s *DirStore) Size() int64 {
+	var size int64
+	filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			size += fileSize(path)
+		}
+		return nil
+	})
+	return size
+}
+
+// BoltStore keeps the cache in a bbolt database, one key per file, so
+// saving only writes the entries that changed.
+type BoltStore struct {
+	path string
+}
+
+// NewBoltStore returns a store keeping the cache in the database at path.
+func NewBoltStore(path string) *BoltStore {
+	return &BoltStore{path: path}
+}
+
+var (
+	boltMeta  = []byte("meta")
+	boltFiles = []byte("files")
+)
+
+// open opens the database, waiting a moment for another build using it.
+func (s *BoltStore) open(readOnly bool) (*bolt.DB, error) {
+	db, err := bolt.Open(s.path, 0644, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: readOnly})
+	if err != nil {
+		switch {
+		case errors.Is(err, bolterrors.ErrTimeout):
+			return nil, fmt.Errorf("cache %s is in use by another build", s.path)
+		case errors.Is(err, bolterrors.ErrInvalid), errors.Is(err, bolterrors.ErrVersionMismatch), errors.Is(err, bolterrors.ErrChecksum):
+			return nil, fmt.Errorf("%w: %v", errCorruptCache, err)
+		}
+		return nil, err
+	}
+	return db, nil
+}
+
+func (s *BoltStore) Load() (CacheData, error) {
+	var data CacheData
+	if _, err := os.Stat(s.path); os.IsNotExist(err) {
+		return data, nil
+	}
+	db, err := s.open(true)
+	if err != nil {
+		return data, err
+	}
+	defer db.Close()
+
+	err = db.View(func(tx *bolt.Tx) error {
+		if meta := tx.Bucket(boltMeta); meta != nil {
+			data.Version, _ = strconv.Atoi(string(meta.Get([]byte("version"))))
+			data.Fingerprint = string(meta.Get([]byte("fingerprint")))
+		}
+		files := tx.Bucket(boltFiles)
+		if files == nil {
+			return nil
+		}
+		data.Files = make(map[string]CachedFile)
+		return files.ForEach(func(k, v []byte) error {
+			var file CachedFile
+			if err := json.Unmarshal(v, &file); err != nil {
+				return fmt.Errorf("%w: %s: %v", errCorruptCache, k, err)
+			}
+			data.Files[string(k)] = file
+			return nil
+		})
+	})
+	if err != nil {
+		return CacheData{}, err
+	}
+	return data, nil
+}
+
+func (s *BoltStore) Save(data CacheData) error {
+	if err := mkdirFor(s.path); err != nil {
+		return err
+	}
+	db, err := s.open(false)
+	if errors.Is(err, errCorruptCache) {
+		// Start over rather than failing every build
+		if err := os.Remove(s.path); err != nil {
+			return err
+		}
+		db, err = s.open(false)
+	}
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(boltMeta)
+		if err != nil {
+			return err
+		}
+		if err := meta.Put([]byte("version"), []byte(strconv.Itoa(data.Version))); err != nil {
+			return err
+		}
+		if err := meta.Put([]byte("fingerprint"), []byte(data.Fingerprint)); err != nil {
+			return err
+		}
+
+		files, err := tx.CreateBucketIfNotExists(boltFiles)
+		if err != nil {
+			return err
+		}
+		var stale [][]byte
+		files.ForEach(func(k, _ []byte) error {
+			if _, ok := data.Files[string(k)]; !ok {
+				stale = append(stale, k)
+			}
+			return nil
+		})
+		for _, k := range stale {
+			if err := files.Delete(k); err != nil {
+				return err
+			}
+		}
+		for path, file := range data.Files {
+			value, err := json.Marshal(file)
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(files.Get([]byte(path)), value) {
+				continue
+			}
+			if err := files.Put([]byte(path), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BoltStore) Clear() error {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *BoltStore) Location() string { return s.path }
+
+func (s *BoltStore) Size() int64 { return fileSize(s.path) }
@@ -0,0 +1,160 @@
+package src
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/iashyam/gossg/src/parser"
+)
+
+func TestCacheStores(t *testing.T) {
+	stores := []struct {
+		name string
+		open func(dir string) CacheStore
+	}{
+		{"JSON file", func(dir string) CacheStore { return NewJSONStore(filepath.Join(dir, "cache.json")) }},
+		{"Directory", func(dir string) CacheStore { return NewDirStore(filepath.Join(dir, "cache")) }},
+		{"bbolt", func(dir string) CacheStore { return NewBoltStore(filepath.Join(dir, "cache.db")) }},
+	}
+
+	first := CacheData{
+		Version:     CacheVersion,
+		Fingerprint: "abc",
+		Files: map[string]CachedFile{
+			"a.md": {Hash: "aaaa", Frontmatter: parser.Frontmatter{Title: "A"}, ContentHTML: "<p>a</p>", WordCount: 1},
+			"b.md": {Hash: "bbbb", ContentHTML: "<p>b</p>"},
+			"c.md": {Hash: "bbbb", ContentHTML: "<p>b</p>"},
+		},
+	}
+	second := CacheData{
+		Version:     CacheVersion,
+		Fingerprint: "def",
+		Files: map[string]CachedFile{
+			"a.md": {Hash: "aaaa", ContentHTML: "<p>A</p>"},
+		},
+	}
+
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			store := tt.open(t.TempDir())
+			load := func(want CacheData) {
+				t.Helper()
+				got, err := store.Load()
+				if err != nil {
+					t.Fatalf("Load() error = %v", err)
+				}
+				if len(got.Files) == 0 && len(want.Files) == 0 {
+					got.Files, want.Files = nil, nil
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("Load() = %+v, want %+v", got, want)
+				}
+			}
+
+			load(CacheData{})
+			if err := store.Save(first); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			load(first)
+			if store.Size() == 0 {
+				t.Errorf("Size() = 0 after Save()")
+			}
+
+			// Saving replaces entries, including those with the same hash
+			// rendered another way
+			if err := store.Save(second); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			load(second)
+
+			if err := store.Clear(); err != nil {
+				t.Fatalf("Clear() error = %v", err)
+			}
+			load(CacheData{})
+			if _, err := os.Stat(store.Location()); !os.IsNotExist(err) {
+				t.Errorf("Clear() left %s behind", store.Location())
+			}
+		})
+	}
+}
+
+func TestCorruptCacheStore(t *testing.T) {
+	dir := t.TempDir()
+	stores := []struct {
+		name    string
+		store   CacheStore
+		corrupt string // file overwritten with garbage
+	}{
+		{"JSON file", NewJSONStore(filepath.Join(dir, "cache.json")), filepath.Join(dir, "cache.json")},
+		{"Directory", NewDirStore(filepath.Join(dir, "cache")), filepath.Join(dir, "cache", "index.json")},
+		{"bbolt", NewBoltStore(filepath.Join(dir, "cache.db")), filepath.Join(dir, "cache.db")},
+	}
+
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.MkdirAll(filepath.Dir(tt.corrupt), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(tt.corrupt, []byte(`{"version": 2, "fi`), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tt.store.Load(); !errors.Is(err, errCorruptCache) {
+				t.Errorf("Load() error = %v, want a corrupt cache", err)
+			}
+
+			// The next build saves a fresh cache over it
+			data := CacheData{Version: CacheVersion, Files: map[string]CachedFile{"a.md": {Hash: "aaaa"}}}
+			if err := tt.store.Save(data); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			got, err := tt.store.Load()
+			if err != nil || len(got.Files) != 1 {
+				t.Errorf("Load() after Save() = %+v, %v, want one entry", got, err)
+			}
+		})
+	}
+}
+
+func TestBoltStoreOSError(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A database that can't be opened isn't corrupt, and must not be removed
+	store := NewBoltStore(filepath.Join(dir, "file", "cache.db"))
+	if _, err := store.Load(); err == nil || errors.Is(err, errCorruptCache) {
+		t.Errorf("Load() error = %v, want the OS error", err)
+	}
+}
+
+func TestOpenCacheStore(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      CacheConfig
+		env      string
+		location string
+		wantErr  bool
+	}{
+		{name: "Default", location: ".gossg_cache.json"},
+		{name: "Directory store", cfg: CacheConfig{Store: "dir"}, location: ".gossg_cache"},
+		{name: "bbolt in a directory", cfg: CacheConfig{Store: "bolt", Dir: "cache"}, location: filepath.Join("cache", "gossg_cache.db")},
+		{name: "Environment overrides the directory", cfg: CacheConfig{Dir: "cache"}, env: "/ci/cache", location: filepath.Join("/ci/cache", "gossg_cache.json")},
+		{name: "Unknown store", cfg: CacheConfig{Store: "redis"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GOSSG_CACHE_DIR", tt.env)
+			store, err := OpenCacheStore(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OpenCacheStore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && store.Location() != tt.location {
+				t.Errorf("Location() = %q, want %q", store.Location(), tt.location)
+			}
+		})
+	}
+}
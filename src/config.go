@@ -0,0 +1,98 @@
+package src
+
+// Config holds the site settings read from config.yaml.
+type Config struct {
+	BaseURL      string `yaml:"baseURL"`
+	SiteName     string `yaml:"siteName"`
+	CustomDomain string `yaml:"customDomain"`
+	// Authors are the author ids used for posts that don't name any.
+	Authors     []string          `yaml:"authors"`
+	TOC         TOCConfig         `yaml:"toc"`
+	ReadingTime ReadingTimeConfig `yaml:"readingTime"`
+	Related     RelatedConfig     `yaml:"related"`
+	Cache       CacheConfig       `yaml:"cache"`
+	Assets      AssetsConfig      `yaml:"assets"`
+	Minify      MinifyConfig      `yaml:"minify"`
+}
+
+// TOCConfig controls heading anchors and tables of contents.
+type TOCConfig struct {
+	// MinLevel and MaxLevel bound the heading levels listed in a table of
+	// contents. They default to 2 and 3.
+	MinLevel int `yaml:"minLevel"`
+	MaxLevel int `yaml:"maxLevel"`
+	// Anchors adds a "#" link to every heading.
+	Anchors bool `yaml:"anchors"`
+}
+
+func (c TOCConfig) withDefaults() TOCConfig {
+	if c.MinLevel == 0 {
+		c.MinLevel = 2
+	}
+	if c.MaxLevel == 0 {
+		c.MaxLevel = 3
+	}
+	return c
+}
+
+// ReadingTimeConfig controls the reading time estimate shown on posts.
+type ReadingTimeConfig struct {
+	// WordsPerMinute is the assumed reading speed. It defaults to 200.
+	WordsPerMinute int `yaml:"wordsPerMinute"`
+}
+
+// RelatedConfig controls the related posts listed under each post.
+type RelatedConfig struct {
+	// Count is the number of related posts, 3 by default. A negative count
+	// turns related posts off.
+	Count int `yaml:"count"`
+	// TagWeight and ContentWeight scale the tag and content similarity of
+	// two posts. They default to 1 and 0.5; a content weight of 0 skips
+	// comparing content.
+	TagWeight     *float64 `yaml:"tagWeight"`
+	ContentWeight *float64 `yaml:"contentWeight"`
+}
+
+type relatedSettings struct {
+	Count                    int
+	TagWeight, ContentWeight float64
+}
+
+func (c RelatedConfig) withDefaults() relatedSettings {
+	s := relatedSettings{Count: c.Count, TagWeight: 1, ContentWeight: 0.5}
+	if s.Count == 0 {
+		s.Count = 3
+	}
+	if c.TagWeight != nil {
+		s.TagWeight = *c.TagWeight
+	}
+	if c.ContentWeight != nil {
+		s.ContentWeight = *c.ContentWeight
+	}
+	return s
+}
+
+// CacheConfig controls where rendered content is cached between builds.
+type CacheConfig struct {
+	// Store is "json" (the default) for a single JSON file, "dir" for a
+	// directory of gzipped entries or "bolt" for a bbolt database.
+	Store string `yaml:"store"`
+	// Dir is the directory holding the cache, the site root by default.
+	// The GOSSG_CACHE_DIR environment variable overrides it.
+	Dir string `yaml:"dir"`
+}
+
+// AssetsConfig controls how the files of content/assets are published.
+type AssetsConfig struct {
+	// Fingerprint adds a hash of its content to the name of every asset, so
+	// they can be cached for as long as a CDN allows.
+	Fingerprint bool `yaml:"fingerprint"`
+}
+
+// MinifyConfig controls what is minified.
+type MinifyConfig struct {
+	// Assets minifies the stylesheets and scripts built from assets/.
+	Assets bool `yaml:"assets"`
+	// HTML minifies the generated pages.
+	HTML bool `yaml:"html"`
+}
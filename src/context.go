@@ -0,0 +1,159 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/iashyam/gossg/src/parser"
+)
+
+// PageContext is the data every template is executed with.
+type PageContext struct {
+	// Page is the content being rendered: a Post, Page, *Author or
+	// ListPage. Every kind has a Title.
+	Page any
+	Site *SiteContext
+	// Paginator is set on paginated listings like the home page.
+	Paginator *Paginator
+}
+
+// SiteContext is the site-wide data available to every template as .Site.
+type SiteContext struct {
+	Config Config
+	// Posts, Pages and Projects are the content sections.
+	Posts    []Post
+	Pages    []Page
+	Projects []Project
+	// Taxonomies maps a taxonomy ("tags", "authors") to its terms and
+	// the posts filed under each term.
+	Taxonomies map[string]map[string][]Post
+	Tags       map[string][]Post
+	Authors    map[string]*Author
+	Data       map[string]any
+	BuildTime  time.Time
+}
+
+// ListPage is a generated page without content of its own, like the
+// timeline or a tag page.
+type ListPage struct {
+	Title string
+	Posts []Post
+}
+
+// Title returns the author's name, so author pages have a title like every
+// other page.
+func (a *Author) Title() string {
+	return a.Name
+}
+
+// Context returns the site-wide template data.
+func (s *Site) Context() *SiteContext {
+	authors := make(map[string][]Post, len(s.Authors))
+	for id, author := range s.Authors {
+		authors[id] = author.Posts
+	}
+	return &SiteContext{
+		Config:   s.Config,
+		Posts:    s.Posts,
+		Pages:    s.Pages,
+		Projects: s.Projects,
+		Taxonomies: map[string]map[string][]Post{
+			"tags":    s.Tags,
+			"authors": authors,
+		},
+		Tags:      s.Tags,
+		Authors:   s.Authors,
+		Data:      s.Data,
+		BuildTime: time.Now(),
+	}
+}
+
+// Paginator is one page of a paginated post listing.
+type Paginator struct {
+	Posts      []Post
+	PageNumber int
+	TotalPages int
+	pagers     []*Paginator
+}
+
+// Paginate splits posts into pages of perPage posts. There is always at
+// least one page, even without posts.
+func Paginate(posts []Post, perPage int) []*Paginator {
+	if perPage <= 0 {
+		perPage = len(posts)
+	}
+	total := 1
+	if perPage > 0 && len(posts) > 0 {
+		total = (len(posts) + perPage - 1) / perPage
+	}
+
+	pagers := make([]*Paginator, total)
+	for i := range pagers {
+		start := i * perPage
+		end := min(start+perPage, len(posts))
+		pagers[i] = &Paginator{
+			Posts:      posts[start:end],
+			PageNumber: i + 1,
+			TotalPages: total,
+			pagers:     pagers,
+		}
+	}
+	return pagers
+}
+
+// URL returns the site path of the page.
+func (p *Paginator) URL() string {
+	if p.PageNumber == 1 {
+		return "/index.html"
+	}
+	return fmt.Sprintf("/page/%d/index.html", p.PageNumber)
+}
+
+// Prev returns the previous page, or nil on the first page.
+func (p *Paginator) Prev() *Paginator {
+	if p.PageNumber <= 1 {
+		return nil
+	}
+	return p.pagers[p.PageNumber-2]
+}
+
+// Next returns the next page, or nil on the last page.
+func (p *Paginator) Next() *Paginator {
+	if p.PageNumber >= p.TotalPages {
+		return nil
+	}
+	return p.pagers[p.PageNumber]
+}
+
+// indexEntry is what listings and other pages show of a file: its front
+// matter, where it is published and its reading time and related posts.
+type indexEntry struct {
+	parser.Frontmatter
+	File        string
+	Slug        string
+	ReadingTime int
+	Related     []string
+}
+
+// IndexHash identifies the metadata of every post, page and project, but not
+// their rendered content. Pages listing or linking to other content are
+// rendered again when it changes.
+func (s *Site) IndexHash() string {
+	var index []indexEntry
+	for _, post := range s.Posts {
+		entry := indexEntry{Frontmatter: post.Frontmatter, File: post.File, Slug: post.Slug, ReadingTime: post.ReadingTime}
+		for _, related := range post.Related {
+			entry.Related = append(entry.Related, related.Slug)
+		}
+		index = append(index, entry)
+	}
+	for _, page := range s.Pages {
+		index = append(index, indexEntry{Frontmatter: page.Frontmatter, File: page.File, Slug: page.Slug, ReadingTime: page.ReadingTime})
+	}
+	for _, project := range s.Projects {
+		index = append(index, indexEntry{Frontmatter: project.Frontmatter, File: project.File, Slug: project.Slug})
+	}
+	data, _ := json.Marshal(index)
+	return ComputeHash(data)
+}
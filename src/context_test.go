@@ -0,0 +1,61 @@
+package src
+
+import "testing"
+
+func TestPaginate(t *testing.T) {
+	posts := make([]Post, 7)
+	tests := []struct {
+		name    string
+		posts   []Post
+		perPage int
+		sizes   []int
+		urls    []string
+	}{
+		{
+			name:    "Last page partly filled",
+			posts:   posts,
+			perPage: 3,
+			sizes:   []int{3, 3, 1},
+			urls:    []string{"/index.html", "/page/2/index.html", "/page/3/index.html"},
+		},
+		{
+			name:    "Single page",
+			posts:   posts,
+			perPage: 10,
+			sizes:   []int{7},
+			urls:    []string{"/index.html"},
+		},
+		{
+			name:    "No posts",
+			perPage: 5,
+			sizes:   []int{0},
+			urls:    []string{"/index.html"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pagers := Paginate(tt.posts, tt.perPage)
+			if len(pagers) != len(tt.sizes) {
+				t.Fatalf("Paginate() returned %d pages, want %d", len(pagers), len(tt.sizes))
+			}
+			for i, pager := range pagers {
+				if len(pager.Posts) != tt.sizes[i] {
+					t.Errorf("page %d has %d posts, want %d", i+1, len(pager.Posts), tt.sizes[i])
+				}
+				if pager.URL() != tt.urls[i] {
+					t.Errorf("page %d URL = %q, want %q", i+1, pager.URL(), tt.urls[i])
+				}
+				if pager.TotalPages != len(tt.sizes) {
+					t.Errorf("page %d TotalPages = %d, want %d", i+1, pager.TotalPages, len(tt.sizes))
+				}
+				if (pager.Prev() == nil) != (i == 0) {
+					t.Errorf("page %d Prev() = %v", i+1, pager.Prev())
+				}
+				if (pager.Next() == nil) != (i == len(pagers)-1) {
+					t.Errorf("page %d Next() = %v", i+1, pager.Next())
+				}
+			}
+		})
+	}
+}
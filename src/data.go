@@ -0,0 +1,111 @@
+package src
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// loadData reads the YAML, JSON, TOML and CSV files under dir into a nested
+// map: data/social/links.yaml is available as .Site.Data.social.links.
+// CSV files become a list of rows keyed by the header row. A missing
+// directory yields an empty map.
+func loadData(dir string) (map[string]any, error) {
+	data := map[string]any{}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return data, nil
+		}
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() {
+			sub, err := loadData(path)
+			if err != nil {
+				return nil, err
+			}
+			data[entry.Name()] = sub
+			continue
+		}
+
+		ext := filepath.Ext(entry.Name())
+		value, err := readDataFile(path, ext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if value == nil {
+			continue // not a data file
+		}
+		data[strings.TrimSuffix(entry.Name(), ext)] = value
+	}
+	return data, nil
+}
+
+// readDataFile decodes the file at path according to its extension. It
+// returns nil for extensions it doesn't know.
+func readDataFile(path, ext string) (any, error) {
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml", ".json", ".toml", ".csv":
+	default:
+		return nil, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &value)
+	case ".json":
+		err = json.Unmarshal(content, &value)
+	case ".toml":
+		var table map[string]any
+		err = toml.Unmarshal(content, &table)
+		value = table
+	case ".csv":
+		value, err = readCSV(content)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		value = map[string]any{} // an empty file
+	}
+	return value, nil
+}
+
+// readCSV turns CSV content into one map per row, keyed by the header row.
+func readCSV(content []byte) ([]map[string]string, error) {
+	records, err := csv.NewReader(strings.NewReader(string(content))).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	rows := []map[string]string{}
+	if len(records) == 0 {
+		return rows, nil
+	}
+
+	header := records[0]
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(record) {
+				row[name] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,78 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadData(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected map[string]any
+		wantErr  bool
+	}{
+		{
+			name: "Formats",
+			files: map[string]string{
+				"social.yaml": "github: iashyam\n",
+				"talks.json":  `[{"title": "Go"}]`,
+				"site.toml":   "theme = \"dark\"\n",
+				"team.csv":    "name,role\nAda,editor\nAlan,\n",
+				"notes.txt":   "ignored",
+			},
+			expected: map[string]any{
+				"social": map[string]any{"github": "iashyam"},
+				"talks":  []any{map[string]any{"title": "Go"}},
+				"site":   map[string]any{"theme": "dark"},
+				"team": []map[string]string{
+					{"name": "Ada", "role": "editor"},
+					{"name": "Alan", "role": ""},
+				},
+			},
+		},
+		{
+			name: "Nested directories",
+			files: map[string]string{
+				"projects/physics.yml": "- name: Mp3\n",
+				"projects/empty.yaml":  "",
+			},
+			expected: map[string]any{
+				"projects": map[string]any{
+					"physics": []any{map[string]any{"name": "Mp3"}},
+					"empty":   map[string]any{},
+				},
+			},
+		},
+		{
+			name:    "Invalid file",
+			files:   map[string]string{"broken.json": "{"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			data, err := loadData(dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(data, tt.expected) {
+				t.Errorf("loadData() = %#v, want %#v", data, tt.expected)
+			}
+		})
+	}
+}
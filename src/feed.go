@@ -0,0 +1,69 @@
+package src
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+	Description string   `xml:"description,omitempty"`
+	Creators    []string `xml:"dc:creator"` // RSS authors must be email addresses
+	Categories  []string `xml:"category"`
+}
+
+// WriteFeed writes posts as an RSS 2.0 feed titled title. link is the page
+// the feed belongs to and baseURL is prefixed to every post URL.
+func WriteFeed(w io.Writer, title, link, baseURL string, posts []Post) error {
+	feed := rss{
+		Version: "2.0",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{Title: title, Link: link, Description: title},
+	}
+	for _, post := range posts {
+		url := baseURL + "/posts/" + post.Slug + ".html"
+		item := rssItem{
+			Title:       post.Title,
+			Link:        url,
+			GUID:        url,
+			Description: post.Description,
+			Categories:  post.Tags,
+		}
+		if t, err := time.Parse("2006-01-02", post.Date); err == nil {
+			item.PubDate = t.Format(time.RFC1123Z)
+		}
+		for _, author := range post.Authors {
+			item.Creators = append(item.Creators, author.Name)
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
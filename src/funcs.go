@@ -0,0 +1,548 @@
+package src
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// FuncMap returns the functions available to every template.
+func FuncMap(cfg Config) template.FuncMap {
+	absURL := func(path string) string {
+		path = strings.TrimSpace(path)
+		if isAbsURL(path) {
+			return path
+		}
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		return cfg.BaseURL + path
+	}
+
+	return template.FuncMap{
+		"url":    absURL,
+		"absURL": absURL,
+		"relURL": func(path string) string {
+			path = strings.TrimSpace(path)
+			if isAbsURL(path) {
+				return path
+			}
+			if !strings.HasPrefix(path, "/") {
+				path = "/" + path
+			}
+			base := ""
+			if u, err := url.Parse(cfg.BaseURL); err == nil {
+				base = strings.TrimSuffix(u.Path, "/")
+			}
+			return base + path
+		},
+		"siteName": func() string {
+			if cfg.SiteName != "" {
+				return cfg.SiteName
+			}
+			return "The Rest Frame"
+		},
+
+		// Strings
+		"lower":       strings.ToLower,
+		"upper":       strings.ToUpper,
+		"slugify":     Slugify,
+		"truncate":    truncate,
+		"plainify":    plainify,
+		"markdownify": markdownify,
+		"readingTime": func(content any) int {
+			words := len(strings.Fields(plainify(content)))
+			return readingTime(words, cfg.ReadingTime.WordsPerMinute)
+		},
+		"dateFormat": dateFormat,
+		"jsonify":    jsonify,
+		"safeHTML":   func(s string) template.HTML { return template.HTML(s) },
+		"safeURL":    func(s string) template.URL { return template.URL(s) },
+
+		// Collections
+		"dict":    dict,
+		"list":    func(items ...any) []any { return items },
+		"first":   first,
+		"last":    last,
+		"after":   after,
+		"where":   where,
+		"sort":    sortBy,
+		"groupBy": groupBy,
+
+		// Math
+		"add": func(a, b any) (any, error) { return arith(a, b, '+') },
+		"sub": func(a, b any) (any, error) { return arith(a, b, '-') },
+		"mul": func(a, b any) (any, error) { return arith(a, b, '*') },
+		"div": func(a, b any) (any, error) { return arith(a, b, '/') },
+		"mod": func(a, b any) (any, error) { return arith(a, b, '%') },
+	}
+}
+
+func isAbsURL(path string) bool {
+	u, err := url.Parse(path)
+	return err == nil && u.IsAbs()
+}
+
+// truncate shortens s to at most n characters, cutting at a word boundary
+// where possible and marking the cut with an ellipsis.
+func truncate(n int, s any) string {
+	text := toString(s)
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	runes := []rune(text)
+	cut := string(runes[:n])
+	if i := strings.LastIndexAny(cut, " \t\n"); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " \t\n.,;:") + "…"
+}
+
+var tagRe = regexp.MustCompile(`<[^>]*>`)
+
+// plainify strips HTML tags from s.
+func plainify(s any) string {
+	return tagRe.ReplaceAllString(toString(s), "")
+}
+
+// markdownify renders s as Markdown. A single paragraph is returned without
+// its <p> wrapper so the result can be used inline.
+func markdownify(s any) (template.HTML, error) {
+	var buf strings.Builder
+	if err := markdown.Convert([]byte(toString(s)), &buf); err != nil {
+		return "", err
+	}
+	out := strings.TrimSpace(buf.String())
+	if strings.HasPrefix(out, "<p>") && strings.HasSuffix(out, "</p>") && strings.Count(out, "<p>") == 1 {
+		out = out[len("<p>") : len(out)-len("</p>")]
+	}
+	return template.HTML(out), nil
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// dateFormat formats date, a time.Time or a string in one of the frontmatter
+// date layouts, using a Go reference-time layout.
+func dateFormat(layout string, date any) (string, error) {
+	switch d := date.(type) {
+	case time.Time:
+		return d.Format(layout), nil
+	case string:
+		for _, l := range dateLayouts {
+			if t, err := time.Parse(l, d); err == nil {
+				return t.Format(layout), nil
+			}
+		}
+		return "", fmt.Errorf("dateFormat: cannot parse date %q", d)
+	}
+	return "", fmt.Errorf("dateFormat: unsupported date type %T", date)
+}
+
+func jsonify(v any) (template.JS, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return template.JS(data), nil
+}
+
+// dict builds a map from alternating keys and values.
+func dict(pairs ...any) (map[string]any, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("dict: odd number of arguments")
+	}
+	m := make(map[string]any, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict: key %v is not a string", pairs[i])
+		}
+		m[key] = pairs[i+1]
+	}
+	return m, nil
+}
+
+// sliceValue returns collection as a reflect.Value of kind Slice or Array.
+func sliceValue(name string, collection any) (reflect.Value, error) {
+	v := indirect(reflect.ValueOf(collection))
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return reflect.Value{}, fmt.Errorf("%s: %T is not a slice", name, collection)
+	}
+	return v, nil
+}
+
+// first returns the first n items of collection.
+func first(n int, collection any) (any, error) {
+	v, err := sliceValue("first", collection)
+	if err != nil {
+		return nil, err
+	}
+	return v.Slice(0, max(0, min(n, v.Len()))).Interface(), nil
+}
+
+// last returns the last n items of collection.
+func last(n int, collection any) (any, error) {
+	v, err := sliceValue("last", collection)
+	if err != nil {
+		return nil, err
+	}
+	return v.Slice(max(0, v.Len()-max(0, n)), v.Len()).Interface(), nil
+}
+
+// after returns the items of collection after the first n.
+func after(n int, collection any) (any, error) {
+	v, err := sliceValue("after", collection)
+	if err != nil {
+		return nil, err
+	}
+	return v.Slice(max(0, min(n, v.Len())), v.Len()).Interface(), nil
+}
+
+// where keeps the items of collection whose key compares to value with op.
+// It is called as `where coll "Key" value` or `where coll "Key" "op" value`,
+// where op is one of ==, !=, <, <=, >, >=, in, "not in" and intersect.
+func where(collection any, key string, args ...any) (any, error) {
+	v, err := sliceValue("where", collection)
+	if err != nil {
+		return nil, err
+	}
+	op, value := "==", any(nil)
+	switch len(args) {
+	case 1:
+		value = args[0]
+	case 2:
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("where: operator %v is not a string", args[0])
+		}
+		op, value = s, args[1]
+	default:
+		return nil, errors.New("where: want a value or an operator and a value")
+	}
+
+	out := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		field, ok := lookup(item, key)
+		if !ok {
+			continue
+		}
+		match, err := compareOp(field, op, value)
+		if err != nil {
+			return nil, fmt.Errorf("where: %w", err)
+		}
+		if match {
+			out = reflect.Append(out, item)
+		}
+	}
+	return out.Interface(), nil
+}
+
+func compareOp(field any, op string, value any) (bool, error) {
+	switch op {
+	case "==", "=", "eq":
+		return compare(field, value) == 0, nil
+	case "!=", "<>", "ne":
+		return compare(field, value) != 0, nil
+	case "<", "lt":
+		return compare(field, value) < 0, nil
+	case "<=", "le":
+		return compare(field, value) <= 0, nil
+	case ">", "gt":
+		return compare(field, value) > 0, nil
+	case ">=", "ge":
+		return compare(field, value) >= 0, nil
+	case "in":
+		return contains(value, field), nil
+	case "not in":
+		return !contains(value, field), nil
+	case "intersect":
+		fv := indirect(reflect.ValueOf(field))
+		if fv.Kind() != reflect.Slice && fv.Kind() != reflect.Array {
+			return false, fmt.Errorf("intersect: %T is not a slice", field)
+		}
+		for i := 0; i < fv.Len(); i++ {
+			if contains(value, fv.Index(i).Interface()) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("unknown operator %q", op)
+}
+
+// contains reports whether collection, a slice or a string, holds item.
+func contains(collection, item any) bool {
+	if s, ok := collection.(string); ok {
+		return strings.Contains(s, toString(item))
+	}
+	v := indirect(reflect.ValueOf(collection))
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return false
+	}
+	for i := 0; i < v.Len(); i++ {
+		if compare(v.Index(i).Interface(), item) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// sortBy returns a sorted copy of collection. It is called as
+// `sort coll`, `sort coll "Key"` or `sort coll "Key" "desc"`.
+func sortBy(collection any, args ...string) (any, error) {
+	v, err := sliceValue("sort", collection)
+	if err != nil {
+		return nil, err
+	}
+	key, desc := "", false
+	if len(args) > 0 {
+		key = args[0]
+	}
+	if len(args) > 1 {
+		switch strings.ToLower(args[1]) {
+		case "asc":
+		case "desc":
+			desc = true
+		default:
+			return nil, fmt.Errorf("sort: unknown order %q", args[1])
+		}
+	}
+
+	out := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), v.Len(), v.Len())
+	reflect.Copy(out, v)
+	keys := make([]any, out.Len())
+	for i := range keys {
+		keys[i] = out.Index(i).Interface()
+		if key != "" {
+			keys[i], _ = lookup(out.Index(i), key)
+		}
+	}
+	swap := reflect.Swapper(out.Interface())
+	sort.Stable(sorter{keys: keys, swap: swap, desc: desc})
+	return out.Interface(), nil
+}
+
+type sorter struct {
+	keys []any
+	swap func(i, j int)
+	desc bool
+}
+
+func (s sorter) Len() int { return len(s.keys) }
+
+func (s sorter) Less(i, j int) bool {
+	if s.desc {
+		return compare(s.keys[i], s.keys[j]) > 0
+	}
+	return compare(s.keys[i], s.keys[j]) < 0
+}
+
+func (s sorter) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.swap(i, j)
+}
+
+// Group is a set of items sharing the same key, produced by groupBy.
+type Group struct {
+	Key   any
+	Items any
+}
+
+// groupBy groups the items of collection by key, keeping the order in which
+// keys first appear.
+func groupBy(collection any, key string) ([]Group, error) {
+	v, err := sliceValue("groupBy", collection)
+	if err != nil {
+		return nil, err
+	}
+	var groups []Group
+	var items []reflect.Value
+	index := map[any]int{}
+	for i := 0; i < v.Len(); i++ {
+		k, _ := lookup(v.Index(i), key)
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			k = fmt.Sprint(k)
+		}
+		g, ok := index[k]
+		if !ok {
+			g = len(groups)
+			index[k] = g
+			groups = append(groups, Group{Key: k})
+			items = append(items, reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), 0, 1))
+		}
+		items[g] = reflect.Append(items[g], v.Index(i))
+	}
+	for i := range groups {
+		groups[i].Items = items[i].Interface()
+	}
+	return groups, nil
+}
+
+// lookup resolves a dotted key like "Frontmatter.Date" against a struct
+// field, a method without arguments or a map entry.
+func lookup(v reflect.Value, key string) (any, bool) {
+	for _, name := range strings.Split(key, ".") {
+		if m := v.MethodByName(name); m.IsValid() && m.Type().NumIn() == 0 && m.Type().NumOut() >= 1 {
+			v = m.Call(nil)[0]
+			continue
+		}
+		v = indirect(v)
+		switch v.Kind() {
+		case reflect.Struct:
+			if v.CanAddr() {
+				if m := v.Addr().MethodByName(name); m.IsValid() && m.Type().NumIn() == 0 && m.Type().NumOut() >= 1 {
+					v = m.Call(nil)[0]
+					continue
+				}
+			}
+			v = v.FieldByName(name)
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			v = v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		default:
+			return nil, false
+		}
+		if !v.IsValid() {
+			return nil, false
+		}
+	}
+	if !v.CanInterface() {
+		return nil, false
+	}
+	return v.Interface(), true
+}
+
+// indirect follows pointers and interfaces to the value they hold.
+func indirect(v reflect.Value) reflect.Value {
+	for (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// compare orders numbers numerically, times chronologically and everything
+// else by its string form.
+func compare(a, b any) int {
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			return ta.Compare(tb)
+		}
+	}
+	return strings.Compare(toString(a), toString(b))
+}
+
+func toString(v any) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case template.HTML:
+		return string(s)
+	}
+	return fmt.Sprint(v)
+}
+
+func toFloat(v any) (float64, bool) {
+	rv := indirect(reflect.ValueOf(v))
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+func toInt(v any) (int64, bool) {
+	rv := indirect(reflect.ValueOf(v))
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), true
+	}
+	return 0, false
+}
+
+// arith applies op to two numbers, staying with integers when both are.
+func arith(a, b any, op byte) (any, error) {
+	if ia, ok := toInt(a); ok {
+		if ib, ok := toInt(b); ok {
+			switch op {
+			case '+':
+				return ia + ib, nil
+			case '-':
+				return ia - ib, nil
+			case '*':
+				return ia * ib, nil
+			case '/', '%':
+				if ib == 0 {
+					return nil, errors.New("division by zero")
+				}
+				if op == '/' {
+					return ia / ib, nil
+				}
+				return ia % ib, nil
+			}
+		}
+	}
+
+	fa, ok := toFloat(a)
+	if !ok {
+		return nil, fmt.Errorf("%v is not a number", a)
+	}
+	fb, ok := toFloat(b)
+	if !ok {
+		return nil, fmt.Errorf("%v is not a number", b)
+	}
+	switch op {
+	case '+':
+		return fa + fb, nil
+	case '-':
+		return fa - fb, nil
+	case '*':
+		return fa * fb, nil
+	case '/':
+		if fb == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return fa / fb, nil
+	case '%':
+		if fb == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return math.Mod(fa, fb), nil
+	}
+	return nil, fmt.Errorf("unknown operator %q", op)
+}
@@ -0,0 +1,80 @@
+package src
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iashyam/gossg/src/parser"
+)
+
+func TestFuncMap(t *testing.T) {
+	posts := []Post{
+		{Frontmatter: parser.Frontmatter{Title: "Go", Date: "2024-03-01", Tags: []string{"go", "code"}}, Year: "2024", ReadingTime: 4},
+		{Frontmatter: parser.Frontmatter{Title: "Waves", Date: "2023-05-20", Tags: []string{"physics"}}, Year: "2023", ReadingTime: 9},
+		{Frontmatter: parser.Frontmatter{Title: "Tests", Date: "2024-01-10", Tags: []string{"code"}}, Year: "2024", ReadingTime: 2},
+	}
+	data := map[string]any{
+		"Posts": posts,
+		"Built": time.Date(2026, 2, 19, 0, 0, 0, 0, time.UTC),
+		"Rows":  []any{map[string]any{"name": "b", "n": 2}, map[string]any{"name": "a", "n": 1}},
+	}
+	cfg := Config{BaseURL: "https://example.com/blog", ReadingTime: ReadingTimeConfig{WordsPerMinute: 2}}
+
+	tests := []struct {
+		name     string
+		tmpl     string
+		expected string
+		wantErr  bool
+	}{
+		{"absURL", `{{ absURL "css/site.css" }} {{ absURL "https://x.io/a" }}`, "https://example.com/blog/css/site.css https://x.io/a", false},
+		{"relURL", `{{ relURL "/tags.html" }}`, "/blog/tags.html", false},
+		{"dateFormat string", `{{ dateFormat "Jan 2, 2006" "2024-12-10" }}`, "Dec 10, 2024", false},
+		{"dateFormat time", `{{ dateFormat "2006" .Built }}`, "2026", false},
+		{"dateFormat invalid", `{{ dateFormat "2006" "soon" }}`, "", true},
+		{"markdownify", `{{ markdownify "*hi* there" }}`, "<em>hi</em> there", false},
+		{"plainify", `{{ plainify "<p>a <b>b</b></p>" }}`, "a b", false},
+		{"truncate", `{{ truncate 12 "The quick brown fox" }}`, "The quick…", false},
+		{"truncate short", `{{ truncate 50 "short" }}`, "short", false},
+		{"slugify", `{{ slugify "Hello, World!" }}`, "hello-world", false},
+		{"readingTime", `{{ readingTime "<p>one two three</p>" }}`, "2", false},
+		{"safeHTML", `{{ safeHTML "<b>x</b>" }}`, "<b>x</b>", false},
+		{"jsonify", `<script>var t = {{ jsonify (list "a" 1) }};</script>`, `<script>var t = ["a",1];</script>`, false},
+		{"dict", `{{ $d := dict "a" 1 "b" "two" }}{{ $d.a }} {{ $d.b }}`, "1 two", false},
+		{"dict odd", `{{ dict "a" }}`, "", true},
+		{"first", `{{ range first 2 .Posts }}{{ .Title }} {{ end }}`, "Go Waves ", false},
+		{"last", `{{ range last 1 .Posts }}{{ .Title }}{{ end }}`, "Tests", false},
+		{"after", `{{ range after 1 .Posts }}{{ .Title }} {{ end }}`, "Waves Tests ", false},
+		{"built-in slice", `{{ range slice .Posts 1 2 }}{{ .Title }} {{ end }}`, "Waves ", false},
+		{"where equal", `{{ range where .Posts "Year" "2024" }}{{ .Title }} {{ end }}`, "Go Tests ", false},
+		{"where operator", `{{ range where .Posts "ReadingTime" ">=" 4 }}{{ .Title }} {{ end }}`, "Go Waves ", false},
+		{"where in", `{{ range where .Posts "Title" "in" (list "Waves" "Tests") }}{{ .Title }} {{ end }}`, "Waves Tests ", false},
+		{"where intersect", `{{ range where .Posts "Tags" "intersect" (list "code") }}{{ .Title }} {{ end }}`, "Go Tests ", false},
+		{"where maps", `{{ range where .Rows "n" "<" 2 }}{{ .name }}{{ end }}`, "a", false},
+		{"where unknown operator", `{{ where .Posts "Year" "~" 1 }}`, "", true},
+		{"sort", `{{ range sort .Posts "Date" }}{{ .Title }} {{ end }}`, "Waves Tests Go ", false},
+		{"sort desc", `{{ range sort .Posts "ReadingTime" "desc" }}{{ .Title }} {{ end }}`, "Waves Go Tests ", false},
+		{"sort values", `{{ range sort (list 3 1 2) }}{{ . }}{{ end }}`, "123", false},
+		{"groupBy", `{{ range groupBy .Posts "Year" }}{{ .Key }}:{{ len .Items }} {{ end }}`, "2024:2 2023:1 ", false},
+		{"math", `{{ add 1 2 }} {{ sub 5 7 }} {{ mul 2 1.5 }} {{ div 7 2 }} {{ mod 7 2 }}`, "3 -2 3 3 1", false},
+		{"division by zero", `{{ div 1 0 }}`, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New(tt.name).Funcs(FuncMap(cfg)).Parse(tt.tmpl)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			var out strings.Builder
+			err = tmpl.Execute(&out, data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && out.String() != tt.expected {
+				t.Errorf("Execute() = %q, want %q", out.String(), tt.expected)
+			}
+		})
+	}
+}
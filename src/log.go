@@ -0,0 +1,133 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+)
+
+// LogHandler writes log records as text or JSON and counts the warnings
+// and errors among them, so a build can fail when there are too many.
+// Warnings and errors are always handled, whatever the level.
+type LogHandler struct {
+	slog.Handler
+	level            slog.Level
+	warnings, errors *atomic.Int64
+}
+
+// NewLogHandler returns a handler writing records of level and above to w
+// in format, which is "text" or "json". Text records leave out the time.
+func NewLogHandler(w io.Writer, level slog.Level, format string) (*LogHandler, error) {
+	opts := &slog.HandlerOptions{Level: min(level, slog.LevelWarn)}
+	var h slog.Handler
+	switch format {
+	case "", "text":
+		opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		}
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q, want text or json", format)
+	}
+	return &LogHandler{Handler: h, level: level, warnings: new(atomic.Int64), errors: new(atomic.Int64)}, nil
+}
+
+func (h *LogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level || level >= slog.LevelWarn
+}
+
+func (h *LogHandler) Handle(ctx context.Context, r slog.Record) error {
+	switch {
+	case r.Level >= slog.LevelError:
+		h.errors.Add(1)
+	case r.Level >= slog.LevelWarn:
+		h.warnings.Add(1)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LogHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level, warnings: h.warnings, errors: h.errors}
+}
+
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	return &LogHandler{Handler: h.Handler.WithGroup(name), level: h.level, warnings: h.warnings, errors: h.errors}
+}
+
+// Warnings and Errors return the number of records logged at those levels.
+func (h *LogHandler) Warnings() int { return int(h.warnings.Load()) }
+func (h *LogHandler) Errors() int   { return int(h.errors.Load()) }
+
+// FileError is a problem with a content file, at Line when it is known.
+type FileError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	switch {
+	case e.File == "":
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	case e.Line == 0:
+		return fmt.Sprintf("%s: %v", e.File, e.Err)
+	default:
+		return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+	}
+}
+
+func (e *FileError) Unwrap() error { return e.Err }
+
+// LogAttrs returns the file, line and error as log attributes.
+func (e *FileError) LogAttrs() []any {
+	attrs := []any{"file", e.File}
+	if e.Line > 0 {
+		attrs = append(attrs, "line", e.Line)
+	}
+	return append(attrs, "err", e.Err)
+}
+
+// LogErrors logs every error joined in err with msg, giving the file and
+// line of those that are a *FileError. It returns the number of errors.
+func LogErrors(msg string, err error) int {
+	if err == nil {
+		return 0
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		n := 0
+		for _, err := range joined.Unwrap() {
+			n += LogErrors(msg, err)
+		}
+		return n
+	}
+
+	var fileErr *FileError
+	if errors.As(err, &fileErr) && fileErr.File != "" {
+		slog.Error(msg, fileErr.LogAttrs()...)
+	} else {
+		slog.Error(msg, "err", err)
+	}
+	return 1
+}
+
+var yamlLineRe = regexp.MustCompile(`line (\d+)`)
+
+// yamlLine returns the line of the first problem in a YAML error, or 0.
+func yamlLine(err error) int {
+	m := yamlLineRe.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0
+	}
+	line, _ := strconv.Atoi(m[1])
+	return line
+}
@@ -0,0 +1,103 @@
+package src
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		level    slog.Level
+		format   string
+		want     []string
+		dontWant []string
+		wantErr  bool
+	}{
+		{
+			name:     "Default level",
+			level:    slog.LevelInfo,
+			want:     []string{`level=INFO msg=info`, `level=WARN msg=warn file=a.md`, `level=ERROR msg=error`},
+			dontWant: []string{"debug", "time="},
+		},
+		{
+			name:  "Verbose",
+			level: slog.LevelDebug,
+			want:  []string{"msg=debug", "msg=info"},
+		},
+		{
+			name:     "Quiet still logs warnings",
+			level:    slog.LevelError,
+			want:     []string{"msg=warn", "msg=error"},
+			dontWant: []string{"msg=info"},
+		},
+		{
+			name:   "JSON",
+			level:  slog.LevelInfo,
+			format: "json",
+			want:   []string{`"level":"WARN","msg":"warn","file":"a.md"`, `"time":`},
+		},
+		{
+			name:    "Unknown format",
+			format:  "xml",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h, err := NewLogHandler(&buf, tt.level, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewLogHandler() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			logger := slog.New(h)
+			logger.Debug("debug")
+			logger.Info("info")
+			logger.With("file", "a.md").Warn("warn")
+			logger.Warn("warn")
+			logger.Error("error")
+
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("log = %q, want it to contain %q", buf.String(), want)
+				}
+			}
+			for _, dontWant := range tt.dontWant {
+				if strings.Contains(buf.String(), dontWant) {
+					t.Errorf("log = %q, want it not to contain %q", buf.String(), dontWant)
+				}
+			}
+			if h.Warnings() != 2 || h.Errors() != 1 {
+				t.Errorf("Warnings(), Errors() = %d, %d, want 2, 1", h.Warnings(), h.Errors())
+			}
+		})
+	}
+}
+
+func TestFileError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *FileError
+		want string
+	}{
+		{"File and line", &FileError{File: "a.md", Line: 3, Err: errors.New("bad")}, "a.md:3: bad"},
+		{"File only", &FileError{File: "a.md", Err: errors.New("bad")}, "a.md: bad"},
+		{"Line only", &FileError{Line: 3, Err: errors.New("bad")}, "line 3: bad"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package src
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+
+	mathjax "github.com/litao91/goldmark-mathjax"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+
+	"github.com/iashyam/gossg/src/parser"
+)
+
+// Heading is a heading of a rendered document.
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	ID    string `json:"id"`
+}
+
+// rendered is the output of rendering one Markdown document.
+type rendered struct {
+	HTML            string
+	TableOfContents string
+	Headings        []Heading
+	WordCount       int
+	ReadingTime     int // minutes
+	// Includes holds the hash of every file included by a shortcode, by path.
+	Includes map[string]string
+}
+
+var markdown = goldmark.New(
+	goldmark.WithExtensions(mathjax.MathJax),
+)
+
+// renderMarkdown converts Markdown to HTML, giving every heading a unique id
+// and building the table of contents unless the frontmatter turns it off.
+// Shortcodes are expanded when shortcodes is not nil.
+//
+// Code blocks and display math are left out of the word count, while inline
+// code and inline math count as one word each.
+func renderMarkdown(source string, fm parser.Frontmatter, cfg Config, shortcodes *Shortcodes) (rendered, error) {
+	var out rendered
+	var expanded []string
+	if shortcodes != nil {
+		var err error
+		out.Includes = map[string]string{}
+		if source, expanded, err = shortcodes.extract(source, out.Includes); err != nil {
+			return out, err
+		}
+	}
+
+	toc := cfg.TOC.withDefaults()
+	src := []byte(source)
+	doc := markdown.Parser().Parse(text.NewReader(src))
+
+	var prose strings.Builder
+	ids := map[string]int{}
+	err := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		if n.Type() == ast.TypeBlock {
+			prose.WriteByte(' ')
+		}
+		switch n := n.(type) {
+		case *ast.Heading:
+			title := restoreText(nodeText(n, src), expanded)
+			id := uniqueID(Slugify(title), ids)
+			n.SetAttributeString("id", []byte(id))
+			if toc.Anchors {
+				anchor := ast.NewString([]byte(fmt.Sprintf(`<a class="heading-anchor" href="#%s" aria-hidden="true">#</a>`, id)))
+				anchor.SetCode(true) // written as is
+				n.AppendChild(n, anchor)
+			}
+			out.Headings = append(out.Headings, Heading{Level: n.Level, Text: title, ID: id})
+		case *ast.FencedCodeBlock, *ast.CodeBlock, *mathjax.MathBlock:
+			return ast.WalkSkipChildren, nil
+		case *ast.CodeSpan, *mathjax.InlineMath:
+			prose.WriteString(" x ")
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			prose.Write(n.Segment.Value(src))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				prose.WriteByte(' ')
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	if err != nil {
+		return out, err
+	}
+	out.WordCount = len(strings.Fields(prose.String()))
+	out.ReadingTime = readingTime(out.WordCount, cfg.ReadingTime.WordsPerMinute)
+
+	var buf strings.Builder
+	if err := markdown.Renderer().Render(&buf, src, doc); err != nil {
+		return out, err
+	}
+	out.HTML = restore(buf.String(), expanded)
+	if fm.TOC == nil || *fm.TOC {
+		out.TableOfContents = buildTOC(out.Headings, toc.MinLevel, toc.MaxLevel)
+	}
+	return out, nil
+}
+
+// readingTime estimates the minutes needed to read words at wpm words per
+// minute, rounding up. Any non-empty text takes at least a minute.
+func readingTime(words, wpm int) int {
+	if wpm <= 0 {
+		wpm = 200
+	}
+	return (words + wpm - 1) / wpm
+}
+
+// nodeText returns the plain text inside n.
+func nodeText(n ast.Node, source []byte) string {
+	var sb strings.Builder
+	ast.Walk(n, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Text:
+			sb.Write(n.Segment.Value(source))
+			if n.SoftLineBreak() {
+				sb.WriteByte(' ')
+			}
+		case *ast.String:
+			sb.Write(n.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(sb.String())
+}
+
+// Slugify turns text into a lowercase, dash-separated identifier for use in
+// URLs and element ids.
+func Slugify(s string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			dash = false
+			sb.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-' || r == '_':
+			dash = true
+		}
+	}
+	return sb.String()
+}
+
+// uniqueID returns slug, or slug with a numeric suffix if an earlier heading
+// already took it.
+func uniqueID(slug string, seen map[string]int) string {
+	if slug == "" {
+		slug = "section"
+	}
+	id := slug
+	for seen[id] > 0 {
+		id = fmt.Sprintf("%s-%d", slug, seen[slug])
+		seen[slug]++
+	}
+	seen[id]++
+	return id
+}
+
+// buildTOC renders the headings between minLevel and maxLevel as nested
+// lists of links. It returns "" when no heading qualifies.
+func buildTOC(headings []Heading, minLevel, maxLevel int) string {
+	var sb strings.Builder
+	var open []int // levels of the open lists
+	for _, h := range headings {
+		if h.Level < minLevel || h.Level > maxLevel {
+			continue
+		}
+		switch {
+		case len(open) == 0 || h.Level > open[len(open)-1]:
+			sb.WriteString("<ul>\n<li>")
+			open = append(open, h.Level)
+		default:
+			for len(open) > 1 && h.Level < open[len(open)-1] {
+				sb.WriteString("</li>\n</ul>\n")
+				open = open[:len(open)-1]
+			}
+			sb.WriteString("</li>\n<li>")
+		}
+		sb.WriteString(fmt.Sprintf(`<a href="#%s">%s</a>`, h.ID, html.EscapeString(h.Text)))
+	}
+	for range open {
+		sb.WriteString("</li>\n</ul>\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,138 @@
+package src
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/iashyam/gossg/src/parser"
+)
+
+func TestRenderMarkdownHeadings(t *testing.T) {
+	off := false
+	tests := []struct {
+		name     string
+		input    string
+		fm       parser.Frontmatter
+		cfg      TOCConfig
+		headings []Heading
+		html     []string // fragments expected in the HTML
+		toc      string
+	}{
+		{
+			name:  "Slugged and deduplicated ids",
+			input: "## Intro\n\n## Intro\n\n### The `x` *value*!\n",
+			headings: []Heading{
+				{Level: 2, Text: "Intro", ID: "intro"},
+				{Level: 2, Text: "Intro", ID: "intro-1"},
+				{Level: 3, Text: "The x value!", ID: "the-x-value"},
+			},
+			html: []string{`<h2 id="intro">Intro</h2>`, `<h2 id="intro-1">Intro</h2>`},
+			toc: "<ul>\n<li><a href=\"#intro\">Intro</a></li>\n<li><a href=\"#intro-1\">Intro</a>" +
+				"<ul>\n<li><a href=\"#the-x-value\">The x value!</a></li>\n</ul>\n</li>\n</ul>\n",
+		},
+		{
+			name:  "Levels outside the range are left out",
+			input: "# Title\n\n## Setup\n\n#### Detail\n",
+			headings: []Heading{
+				{Level: 1, Text: "Title", ID: "title"},
+				{Level: 2, Text: "Setup", ID: "setup"},
+				{Level: 4, Text: "Detail", ID: "detail"},
+			},
+			toc: "<ul>\n<li><a href=\"#setup\">Setup</a></li>\n</ul>\n",
+		},
+		{
+			name:  "Anchors",
+			input: "## A & B\n",
+			cfg:   TOCConfig{Anchors: true},
+			headings: []Heading{
+				{Level: 2, Text: "A & B", ID: "a-b"},
+			},
+			html: []string{`<h2 id="a-b">A &amp; B<a class="heading-anchor" href="#a-b" aria-hidden="true">#</a></h2>`},
+			toc:  "<ul>\n<li><a href=\"#a-b\">A &amp; B</a></li>\n</ul>\n",
+		},
+		{
+			name:  "Turned off in frontmatter",
+			input: "## Intro\n",
+			fm:    parser.Frontmatter{TOC: &off},
+			headings: []Heading{
+				{Level: 2, Text: "Intro", ID: "intro"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := renderMarkdown(tt.input, tt.fm, Config{TOC: tt.cfg}, nil)
+			if err != nil {
+				t.Fatalf("renderMarkdown() error = %v", err)
+			}
+			if !reflect.DeepEqual(out.Headings, tt.headings) {
+				t.Errorf("Headings = %+v, want %+v", out.Headings, tt.headings)
+			}
+			for _, fragment := range tt.html {
+				if !strings.Contains(out.HTML, fragment) {
+					t.Errorf("HTML = %q, missing %q", out.HTML, fragment)
+				}
+			}
+			if out.TableOfContents != tt.toc {
+				t.Errorf("TableOfContents = %q, want %q", out.TableOfContents, tt.toc)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdownWordCount(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		wpm         int
+		wordCount   int
+		readingTime int
+	}{
+		{
+			name:        "Prose across blocks",
+			input:       "# One two\n\nthree *four* five\nsix\n\n- seven\n- eight",
+			wordCount:   8,
+			readingTime: 1,
+		},
+		{
+			name:        "Code blocks are skipped",
+			input:       "Run this:\n\n```go\nfmt.Println(\"not counted\")\n```\n",
+			wordCount:   2,
+			readingTime: 1,
+		},
+		{
+			name:        "Inline code and math count as one word",
+			input:       "Set `x := 1` so $a + b = c$ holds.\n\n$$\ne = mc^2\n$$\n",
+			wordCount:   5,
+			readingTime: 1,
+		},
+		{
+			name:        "Reading time rounds up",
+			input:       strings.Repeat("word ", 250),
+			wpm:         100,
+			wordCount:   250,
+			readingTime: 3,
+		},
+		{
+			name: "Empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{ReadingTime: ReadingTimeConfig{WordsPerMinute: tt.wpm}}
+			out, err := renderMarkdown(tt.input, parser.Frontmatter{}, cfg, nil)
+			if err != nil {
+				t.Fatalf("renderMarkdown() error = %v", err)
+			}
+			if out.WordCount != tt.wordCount {
+				t.Errorf("WordCount = %d, want %d", out.WordCount, tt.wordCount)
+			}
+			if out.ReadingTime != tt.readingTime {
+				t.Errorf("ReadingTime = %d, want %d", out.ReadingTime, tt.readingTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,371 @@
+package src
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// The minifiers below only remove what can't change the meaning of a file:
+// comments and whitespace. Strings, and in JavaScript template literals and
+// regular expressions, are copied as written.
+
+// MinifyCSS removes comments and unneeded whitespace from a stylesheet.
+func MinifyCSS(src []byte) []byte {
+	var out bytes.Buffer
+	space := false
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				i = len(src)
+				break
+			}
+			i += end + 3
+			space = true
+		case isSpace(c):
+			space = true
+		default:
+			if space && out.Len() > 0 && !strings.ContainsRune("{};,>:", rune(lastByte(&out))) && !strings.ContainsRune("{};,>!", rune(c)) {
+				out.WriteByte(' ')
+			}
+			space = false
+
+			switch {
+			case c == '"' || c == '\'':
+				i = copyString(&out, src, i)
+			case c == '}' && lastByte(&out) == ';':
+				out.Truncate(out.Len() - 1)
+				out.WriteByte(c)
+			case c == '(' && bytes.HasSuffix(out.Bytes(), []byte("url")) && i+1 < len(src) && src[i+1] != '"' && src[i+1] != '\'':
+				// An unquoted URL may hold anything but a closing parenthesis
+				end := bytes.IndexByte(src[i:], ')')
+				if end < 0 {
+					end = len(src) - i - 1
+				}
+				out.Write(src[i : i+end+1])
+				i += end
+			default:
+				out.WriteByte(c)
+			}
+		}
+	}
+	return out.Bytes()
+}
+
+// jsKeywords are the keywords a regular expression can follow.
+var jsKeywords = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true,
+	"new": true, "delete": true, "void": true, "throw": true, "case": true,
+	"do": true, "else": true, "yield": true, "await": true,
+}
+
+// MinifyJS removes comments and unneeded whitespace from a script. Line
+// breaks are kept unless the tokens around them show that a statement
+// continues, as a semicolon may have been left out.
+func MinifyJS(src []byte) []byte {
+	var out bytes.Buffer
+	minifyJS(&out, src, 0, false)
+	return out.Bytes()
+}
+
+// minifyJS copies src from i to out. In a template literal substitution it
+// stops after the closing brace and returns the index of that brace.
+func minifyJS(out *bytes.Buffer, src []byte, i int, substitution bool) int {
+	depth := 0
+	space, newline := false, false
+	for ; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			i--
+			space = true
+			continue
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(src) - i - 2
+			}
+			if bytes.IndexByte(src[i+2:i+2+end], '\n') >= 0 {
+				newline = true
+			}
+			i += end + 3
+			space = true
+			continue
+		case c == '\n':
+			space, newline = true, true
+			continue
+		case isSpace(c):
+			space = true
+			continue
+		}
+
+		last := lastByte(out)
+		if space && out.Len() > 0 {
+			switch {
+			case newline && !strings.ContainsRune("{[(,;:=|&*%<>!?~^", rune(last)) && !strings.ContainsRune("}]),;:.?=|&*%<>", rune(c)):
+				out.WriteByte('\n')
+			case isWordByte(last) && isWordByte(c), (last == '+' || last == '-') && c == last:
+				out.WriteByte(' ')
+			}
+		}
+		space, newline = false, false
+
+		switch {
+		case c == '"' || c == '\'':
+			i = copyString(out, src, i)
+		case c == '`':
+			i = copyTemplate(out, src, i)
+		case c == '/' && regexAllowed(out):
+			i = copyRegex(out, src, i)
+		case c == '{':
+			depth++
+			out.WriteByte(c)
+		case c == '}':
+			if substitution && depth == 0 {
+				out.WriteByte(c)
+				return i
+			}
+			depth--
+			out.WriteByte(c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return i
+}
+
+// regexAllowed reports whether a slash after out starts a regular
+// expression rather than being a division.
+func regexAllowed(out *bytes.Buffer) bool {
+	b := bytes.TrimRight(out.Bytes(), " \n")
+	if len(b) == 0 {
+		return true
+	}
+	last := b[len(b)-1]
+	if !isWordByte(last) {
+		return !strings.ContainsRune(")]}", rune(last))
+	}
+	start := len(b)
+	for start > 0 && isWordByte(b[start-1]) {
+		start--
+	}
+	return jsKeywords[string(b[start:])]
+}
+
+// copyString copies the string starting with the quote at src[i] and
+// returns the index of its closing quote.
+func copyString(out *bytes.Buffer, src []byte, i int) int {
+	quote := src[i]
+	out.WriteByte(quote)
+	for i++; i < len(src); i++ {
+		out.WriteByte(src[i])
+		switch src[i] {
+		case '\\':
+			if i+1 < len(src) {
+				i++
+				out.WriteByte(src[i])
+			}
+		case quote, '\n':
+			return i
+		}
+	}
+	return i
+}
+
+// copyTemplate copies the template literal starting at src[i], minifying
+// its substitutions, and returns the index of its closing backtick.
+func copyTemplate(out *bytes.Buffer, src []byte, i int) int {
+	out.WriteByte('`')
+	for i++; i < len(src); i++ {
+		c := src[i]
+		out.WriteByte(c)
+		switch {
+		case c == '\\' && i+1 < len(src):
+			i++
+			out.WriteByte(src[i])
+		case c == '`':
+			return i
+		case c == '$' && i+1 < len(src) && src[i+1] == '{':
+			out.WriteByte('{')
+			i = minifyJS(out, src, i+2, true)
+		}
+	}
+	return i
+}
+
+// copyRegex copies the regular expression starting at src[i] and returns
+// the index of its last flag.
+func copyRegex(out *bytes.Buffer, src []byte, i int) int {
+	out.WriteByte('/')
+	class := false
+	for i++; i < len(src); i++ {
+		c := src[i]
+		out.WriteByte(c)
+		switch {
+		case c == '\\' && i+1 < len(src):
+			i++
+			out.WriteByte(src[i])
+		case c == '[':
+			class = true
+		case c == ']':
+			class = false
+		case c == '/' && !class, c == '\n':
+			for i+1 < len(src) && isWordByte(src[i+1]) {
+				i++
+				out.WriteByte(src[i])
+			}
+			return i
+		}
+	}
+	return i
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// isWordByte reports whether c can be part of an identifier, keyword or
+// number. Bytes of multi-byte characters count.
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c == '\\' || c >= 0x80 ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+func lastByte(b *bytes.Buffer) byte {
+	if b.Len() == 0 {
+		return 0
+	}
+	return b.Bytes()[b.Len()-1]
+}
+
+var (
+	htmlCommentRe = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlSpaceRe   = regexp.MustCompile(`\s+`)
+)
+
+// htmlRawTags hold text whose whitespace matters or that isn't HTML.
+var htmlRawTags = []string{"pre", "textarea", "script", "style"}
+
+// MinifyHTML removes comments from a page and collapses runs of whitespace
+// outside of tags to a single space, which a browser renders the same.
+// Inside tags whitespace between attributes is collapsed. The content of
+// pre, textarea, script and style elements is left alone, as are
+// conditional comments.
+func MinifyHTML(src []byte) []byte {
+	var out bytes.Buffer
+	for i := 0; i < len(src); {
+		lt := bytes.IndexByte(src[i:], '<')
+		if lt < 0 {
+			writeText(&out, src[i:])
+			break
+		}
+		writeText(&out, src[i:i+lt])
+		i += lt
+		if i+1 < len(src) && !isTagStart(src[i+1]) {
+			out.WriteByte('<')
+			i++
+			continue
+		}
+
+		if bytes.HasPrefix(src[i:], []byte("<!--")) {
+			loc := htmlCommentRe.FindIndex(src[i:])
+			if loc == nil {
+				out.Write(src[i:])
+				break
+			}
+			if bytes.HasPrefix(src[i:], []byte("<!--[if")) {
+				out.Write(src[i : i+loc[1]])
+			}
+			i += loc[1]
+			continue
+		}
+
+		end := copyTag(&out, src, i)
+		name := tagName(src[i:end])
+		i = end
+		for _, raw := range htmlRawTags {
+			if name != raw {
+				continue
+			}
+			close := indexFold(src[i:], "</"+raw)
+			if close < 0 {
+				close = len(src) - i
+			}
+			out.Write(src[i : i+close])
+			i += close
+		}
+	}
+	return bytes.TrimSpace(out.Bytes())
+}
+
+// writeText writes text with its whitespace collapsed, also with any
+// whitespace out ends with, as where a comment was removed.
+func writeText(out *bytes.Buffer, text []byte) {
+	text = htmlSpaceRe.ReplaceAll(text, []byte(" "))
+	if lastByte(out) == ' ' {
+		text = bytes.TrimPrefix(text, []byte(" "))
+	}
+	out.Write(text)
+}
+
+// copyTag copies the tag starting at src[i], collapsing the whitespace
+// between its attributes, and returns the index after it.
+func copyTag(out *bytes.Buffer, src []byte, i int) int {
+	space := false
+	for ; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case isSpace(c):
+			space = true
+			continue
+		case space && c != '>' && !(c == '/' && i+1 < len(src) && src[i+1] == '>'):
+			out.WriteByte(' ')
+		}
+		space = false
+		switch c {
+		case '"', '\'':
+			end := bytes.IndexByte(src[i+1:], c)
+			if end < 0 {
+				end = len(src) - i - 2
+			}
+			out.Write(src[i : i+end+2])
+			i += end + 1
+		case '>':
+			out.WriteByte(c)
+			return i + 1
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return i
+}
+
+// tagName returns the lower case name of an opening tag, or "".
+func tagName(tag []byte) string {
+	name := bytes.TrimPrefix(tag, []byte("<"))
+	if end := bytes.IndexFunc(name, func(r rune) bool { return r == '>' || r == '/' || r <= ' ' }); end >= 0 {
+		name = name[:end]
+	}
+	return strings.ToLower(string(name))
+}
+
+func isTagStart(c byte) bool {
+	return c == '/' || c == '!' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// indexFold returns the index of the first instance of the lower case ASCII
+// string s in b, ignoring case, or -1.
+func indexFold(b []byte, s string) int {
+	for i := 0; i+len(s) <= len(b); i++ {
+		if strings.EqualFold(string(b[i:i+len(s)]), s) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,81 @@
+package src
+
+import "testing"
+
+func TestMinify(t *testing.T) {
+	tests := []struct {
+		name     string
+		minify   func([]byte) []byte
+		input    string
+		expected string
+	}{
+		{
+			name:     "CSS whitespace and comments",
+			minify:   MinifyCSS,
+			input:    "/* theme */\nbody {\n  margin: 0 auto;\n  color: red !important;\n}\n\na > b,\nc ~ d { top: 0 }\n",
+			expected: "body{margin:0 auto;color:red!important}a>b,c ~ d{top:0}",
+		},
+		{
+			name:     "CSS keeps meaningful spaces",
+			minify:   MinifyCSS,
+			input:    ".post :hover { width: calc(100% - 2px) }\n@media screen and (min-width: 40em) { p { x: 1 } }",
+			expected: ".post :hover{width:calc(100% - 2px)}@media screen and (min-width:40em){p{x:1}}",
+		},
+		{
+			name:     "CSS strings and URLs",
+			minify:   MinifyCSS,
+			input:    `a { content: "  /* no */  "; background: url(data:a/*b*/c) }`,
+			expected: `a{content:"  /* no */  ";background:url(data:a/*b*/c)}`,
+		},
+		{
+			name:     "JS whitespace and comments",
+			minify:   MinifyJS,
+			input:    "// setup\nfunction add(a, b) {\n  /* sum */\n  return a + b;\n}\n",
+			expected: "function add(a,b){return a+b;}",
+		},
+		{
+			name:     "JS keeps line breaks that may end statements",
+			minify:   MinifyJS,
+			input:    "let a = 1\nlet b = a\n++a\nfoo(a,\n  b)\n  .then(c)\n",
+			expected: "let a=1\nlet b=a\n++a\nfoo(a,b).then(c)",
+		},
+		{
+			name:     "JS operators",
+			minify:   MinifyJS,
+			input:    "x = a - -b + +c / 2",
+			expected: "x=a- -b+ +c/2",
+		},
+		{
+			name:     "JS strings, templates and regular expressions",
+			minify:   MinifyJS,
+			input:    "s = 'a  // b' + `c  ${ d + `e ${ f }` }  g`\nr = /[/]\\/ +/g.test(s)\nreturn /x/",
+			expected: "s='a  // b'+`c  ${d+`e ${f}`}  g`\nr=/[/]\\/ +/g.test(s)\nreturn/x/",
+		},
+		{
+			name:     "HTML whitespace and comments",
+			minify:   MinifyHTML,
+			input:    "<!DOCTYPE html>\n<html>\n  <!-- nav -->\n  <a\n    href=\"/a  b\"   class='x'>Link</a>\n  <p>One\n    two</p>\n</html>\n",
+			expected: "<!DOCTYPE html> <html> <a href=\"/a  b\" class='x'>Link</a> <p>One two</p> </html>",
+		},
+		{
+			name:     "HTML raw elements",
+			minify:   MinifyHTML,
+			input:    "<pre><code>a\n  b</code></pre>\n<SCRIPT>if (a < b)  c()</SCRIPT>\n<textarea> x </textarea>",
+			expected: "<pre><code>a\n  b</code></pre> <SCRIPT>if (a < b)  c()</SCRIPT> <textarea> x </textarea>",
+		},
+		{
+			name:     "HTML conditional comments and text",
+			minify:   MinifyHTML,
+			input:    "<!--[if IE]><p>old</p><![endif]-->\n<p>1 < 2</p>",
+			expected: "<!--[if IE]><p>old</p><![endif]--> <p>1 < 2</p>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(tt.minify([]byte(tt.input))); got != tt.expected {
+				t.Errorf("got %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package src
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Parallel calls fn(i) for every i from 0 to n-1, running up to workers
+// calls at once, and returns when all of them have. With workers below 1 it
+// runs one call per CPU. Callers keep results in order by writing them to
+// index i of a slice.
+func Parallel(n, workers int, fn func(i int)) {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	workers = min(workers, n)
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := range n {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
@@ -0,0 +1,47 @@
+package src
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestParallel(t *testing.T) {
+	tests := []struct {
+		name    string
+		n       int
+		workers int
+	}{
+		{"No work", 0, 4},
+		{"One worker", 10, 1},
+		{"More work than workers", 100, 4},
+		{"More workers than work", 3, 8},
+		{"One worker per CPU", 50, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make([]int, tt.n)
+			var running, most atomic.Int32
+			Parallel(tt.n, tt.workers, func(i int) {
+				now := running.Add(1)
+				for {
+					m := most.Load()
+					if now <= m || most.CompareAndSwap(m, now) {
+						break
+					}
+				}
+				done[i]++
+				running.Add(-1)
+			})
+
+			for i, calls := range done {
+				if calls != 1 {
+					t.Errorf("fn(%d) called %d times, want 1", i, calls)
+				}
+			}
+			if tt.workers > 0 && int(most.Load()) > tt.workers {
+				t.Errorf("%d calls ran at once, want at most %d", most.Load(), tt.workers)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+// Package ast defines the document tree produced by the native Markdown
+// parser, so that build stages can inspect and transform content before it
+// is rendered.
+package ast
+
+// Position is a location in the Markdown source.
+type Position struct {
+	Offset int // byte offset, starting at 0
+	Line   int // line number, starting at 1
+	Column int // byte offset within the line, starting at 1
+}
+
+// Node is an element of the document tree.
+type Node interface {
+	// Pos returns where the node starts in the source.
+	Pos() Position
+	SetPos(Position)
+	// Children returns the nodes directly below this one, in source order.
+	Children() []Node
+	AppendChild(Node)
+}
+
+// base holds the state shared by every node.
+type base struct {
+	pos      Position
+	children []Node
+}
+
+func (b *base) Pos() Position       { return b.pos }
+func (b *base) SetPos(pos Position) { b.pos = pos }
+func (b *base) Children() []Node    { return b.children }
+func (b *base) AppendChild(n Node)  { b.children = append(b.children, n) }
+
+// Document is the root of a parsed Markdown file.
+type Document struct{ base }
+
+// Heading is an ATX heading (# to ######).
+type Heading struct {
+	base
+	Level int
+}
+
+// Paragraph is a run of inline content.
+type Paragraph struct{ base }
+
+// Blockquote holds quoted blocks.
+type Blockquote struct{ base }
+
+// List is a bullet or ordered list of ListItem nodes.
+type List struct {
+	base
+	Ordered bool
+	Start   int  // number of the first item of an ordered list
+	Marker  byte // '-', '*', '+', '.' or ')'
+	// Tight lists have no blank lines between their items or blocks, and
+	// render their paragraphs without <p> tags.
+	Tight bool
+}
+
+// TaskState is the checkbox state of a list item.
+type TaskState int
+
+const (
+	NoTask TaskState = iota
+	TaskUnchecked
+	TaskChecked
+)
+
+// ListItem is an item of a List.
+type ListItem struct {
+	base
+	Task TaskState
+}
+
+// CodeBlock is a fenced or indented code block.
+type CodeBlock struct {
+	base
+	Info    string // info string after the opening fence
+	Literal string
+}
+
+// Language returns the first word of the info string.
+func (c *CodeBlock) Language() string {
+	for i := 0; i < len(c.Info); i++ {
+		if c.Info[i] == ' ' || c.Info[i] == '\t' {
+			return c.Info[:i]
+		}
+	}
+	return c.Info
+}
+
+// HTMLBlock is a block of raw HTML.
+type HTMLBlock struct {
+	base
+	Literal string
+}
+
+// Alignment is the text alignment of a table column.
+type Alignment string
+
+const (
+	AlignNone   Alignment = ""
+	AlignLeft   Alignment = "left"
+	AlignCenter Alignment = "center"
+	AlignRight  Alignment = "right"
+)
+
+// Table is a GFM pipe table. Its first row is the header.
+type Table struct {
+	base
+	Alignments []Alignment
+}
+
+// TableRow is a row of TableCell nodes.
+type TableRow struct {
+	base
+	Header bool
+}
+
+// TableCell is a single cell of a table row.
+type TableCell struct {
+	base
+	Header    bool
+	Alignment Alignment
+}
+
+// ThematicBreak is a horizontal rule.
+type ThematicBreak struct{ base }
+
+// Text is plain text.
+type Text struct {
+	base
+	Literal string
+}
+
+// SoftBreak is a line break inside a paragraph.
+type SoftBreak struct{ base }
+
+// Emphasis is *emphasis* (level 1) or **strong emphasis** (level 2).
+type Emphasis struct {
+	base
+	Level int
+}
+
+// Strikethrough is ~deleted~ text.
+type Strikethrough struct{ base }
+
+// Code is an inline code span.
+type Code struct {
+	base
+	Literal string
+}
+
+// RawHTML is an inline HTML tag or comment.
+type RawHTML struct {
+	base
+	Literal string
+}
+
+// Math is a TeX math expression, without its delimiters unless it is a
+// LaTeX environment.
+type Math struct {
+	base
+	Literal string
+	Display bool
+	// Environment names the \begin{...} environment that Literal holds in
+	// full, or is empty.
+	Environment string
+}
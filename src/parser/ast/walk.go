@@ -0,0 +1,44 @@
+package ast
+
+// WalkStatus tells Walk how to go on after visiting a node.
+type WalkStatus int
+
+const (
+	// WalkContinue visits the node's children and then its siblings.
+	WalkContinue WalkStatus = iota
+	// WalkSkipChildren moves on to the node's next sibling.
+	WalkSkipChildren
+	// WalkStop ends the walk.
+	WalkStop
+)
+
+// Walker is called when Walk enters a node, before its children, and again
+// when it leaves it. The status returned when leaving only matters for
+// WalkStop.
+type Walker func(n Node, entering bool) (WalkStatus, error)
+
+// Walk visits n and its descendants depth-first. It stops at the first
+// error returned by fn.
+func Walk(n Node, fn Walker) error {
+	_, err := walk(n, fn)
+	return err
+}
+
+func walk(n Node, fn Walker) (WalkStatus, error) {
+	status, err := fn(n, true)
+	if err != nil || status == WalkStop {
+		return WalkStop, err
+	}
+	if status != WalkSkipChildren {
+		for _, child := range n.Children() {
+			if status, err := walk(child, fn); err != nil || status == WalkStop {
+				return WalkStop, err
+			}
+		}
+	}
+	status, err = fn(n, false)
+	if err != nil || status == WalkStop {
+		return WalkStop, err
+	}
+	return WalkContinue, nil
+}
@@ -0,0 +1,110 @@
+package ast
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// sample builds: Document > [Heading > Text "a", Paragraph > [Text "b", Emphasis > Text "c"]]
+func sample() *Document {
+	doc := &Document{}
+	heading := &Heading{Level: 1}
+	heading.AppendChild(&Text{Literal: "a"})
+	para := &Paragraph{}
+	para.AppendChild(&Text{Literal: "b"})
+	em := &Emphasis{Level: 1}
+	em.AppendChild(&Text{Literal: "c"})
+	para.AppendChild(em)
+	doc.AppendChild(heading)
+	doc.AppendChild(para)
+	return doc
+}
+
+func TestWalk(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   func(n Node) WalkStatus
+		expected []string
+	}{
+		{
+			name:     "Visits every node",
+			status:   func(Node) WalkStatus { return WalkContinue },
+			expected: []string{"+doc", "+h", "+a", "-a", "-h", "+p", "+b", "-b", "+em", "+c", "-c", "-em", "-p", "-doc"},
+		},
+		{
+			name: "Skip children",
+			status: func(n Node) WalkStatus {
+				if _, ok := n.(*Heading); ok {
+					return WalkSkipChildren
+				}
+				return WalkContinue
+			},
+			expected: []string{"+doc", "+h", "-h", "+p", "+b", "-b", "+em", "+c", "-c", "-em", "-p", "-doc"},
+		},
+		{
+			name: "Stop",
+			status: func(n Node) WalkStatus {
+				if text, ok := n.(*Text); ok && text.Literal == "b" {
+					return WalkStop
+				}
+				return WalkContinue
+			},
+			expected: []string{"+doc", "+h", "+a", "-a", "-h", "+p", "+b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var visited []string
+			err := Walk(sample(), func(n Node, entering bool) (WalkStatus, error) {
+				prefix := "-"
+				if entering {
+					prefix = "+"
+				}
+				visited = append(visited, prefix+name(n))
+				return tt.status(n), nil
+			})
+			if err != nil {
+				t.Fatalf("Walk() error = %v", err)
+			}
+			if !reflect.DeepEqual(visited, tt.expected) {
+				t.Errorf("Walk() visited %v, want %v", visited, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWalkError(t *testing.T) {
+	errBoom := errors.New("boom")
+	count := 0
+	err := Walk(sample(), func(n Node, entering bool) (WalkStatus, error) {
+		count++
+		if _, ok := n.(*Paragraph); ok {
+			return WalkContinue, errBoom
+		}
+		return WalkContinue, nil
+	})
+	if !errors.Is(err, errBoom) {
+		t.Errorf("Walk() error = %v, want %v", err, errBoom)
+	}
+	if count != 6 {
+		t.Errorf("Walk() made %d calls before stopping, want 6", count)
+	}
+}
+
+func name(n Node) string {
+	switch n := n.(type) {
+	case *Document:
+		return "doc"
+	case *Heading:
+		return "h"
+	case *Paragraph:
+		return "p"
+	case *Emphasis:
+		return "em"
+	case *Text:
+		return n.Literal
+	}
+	return "?"
+}
@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/iashyam/gossg/src/parser/ast"
+)
+
+func TestParsePositions(t *testing.T) {
+	input := "# Title\n\nSome *text*\n\n- item\n\n| a | b |\n|---|---|\n| 1 | `2` |"
+	doc := NewParser(Tokenize(input)).Parse()
+
+	var headings []*ast.Heading
+	positions := map[string]ast.Position{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Heading:
+			headings = append(headings, n)
+		case *ast.Text:
+			positions[n.Literal] = n.Pos()
+		case *ast.Code:
+			positions["`"+n.Literal+"`"] = n.Pos()
+		}
+		return ast.WalkContinue, nil
+	})
+
+	if len(headings) != 1 || headings[0].Level != 1 {
+		t.Fatalf("found headings %v, want one level 1 heading", headings)
+	}
+
+	expected := map[string]ast.Position{
+		"Title": {Offset: 2, Line: 1, Column: 3},
+		"Some ": {Offset: 9, Line: 3, Column: 1},
+		"text":  {Offset: 15, Line: 3, Column: 7},
+		"item":  {Offset: 24, Line: 5, Column: 3},
+		"b":     {Offset: 36, Line: 7, Column: 7},
+		"1":     {Offset: 52, Line: 9, Column: 3},
+		"`2`":   {Offset: 56, Line: 9, Column: 7},
+	}
+	for text, want := range expected {
+		if got := positions[text]; got != want {
+			t.Errorf("position of %q = %+v, want %+v", text, got, want)
+		}
+	}
+}
+
+func TestRenderText(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Markup is dropped",
+			input:    "# Hello *world*\n\nTom &amp; <b>Jerry</b>\nagain",
+			expected: "Hello world\nTom & Jerry again\n",
+		},
+		{
+			name:     "Lists and code",
+			input:    "- one `x`\n- two\n\n```\ncode\n```",
+			expected: "one x\ntwo\ncode\n",
+		},
+		{
+			name:     "Raw HTML blocks are dropped",
+			input:    "<div>\nhidden\n</div>\n\nshown",
+			expected: "shown\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := NewParser(Tokenize(tt.input)).Parse()
+			if got := RenderText(doc); got != tt.expected {
+				t.Errorf("RenderText() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -14,6 +14,11 @@ type Frontmatter struct {
 	Image       string   `yaml:"image"`
 	Link        string   `yaml:"link"`
 	Description string   `yaml:"description"`
+	// AuthorIDs names the post's authors. A single Author is accepted too.
+	AuthorIDs []string `yaml:"authors"`
+	Author    string   `yaml:"author"`
+	// TOC turns the table of contents off when set to false.
+	TOC *bool `yaml:"toc"`
 }
 
 // ExtractFrontmatter separates the YAML frontmatter from the Markdown content.
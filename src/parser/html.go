@@ -0,0 +1,304 @@
+package parser
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// HTMLPolicy decides what happens to raw HTML found in Markdown.
+type HTMLPolicy int
+
+const (
+	// HTMLPassthrough writes raw HTML to the output unchanged.
+	HTMLPassthrough HTMLPolicy = iota
+	// HTMLEscape writes raw HTML as visible, escaped text.
+	HTMLEscape
+	// HTMLSanitize keeps only the tags and attributes in the allowlist.
+	HTMLSanitize
+)
+
+// Allowlist maps the tags kept by HTMLSanitize to their allowed attributes.
+type Allowlist map[string][]string
+
+// DefaultAllowlist covers the formatting and embedding markup used in posts,
+// such as the "Open in Colab" badge.
+var DefaultAllowlist = Allowlist{
+	"a":          {"href", "title", "target", "rel"},
+	"abbr":       {"title"},
+	"b":          nil,
+	"blockquote": {"cite"},
+	"br":         nil,
+	"code":       {"class"},
+	"dd":         nil,
+	"del":        nil,
+	"details":    {"open"},
+	"div":        {"class", "id"},
+	"dl":         nil,
+	"dt":         nil,
+	"em":         nil,
+	"figcaption": nil,
+	"figure":     {"class", "id"},
+	"h1":         {"id"},
+	"h2":         {"id"},
+	"h3":         {"id"},
+	"h4":         {"id"},
+	"h5":         {"id"},
+	"h6":         {"id"},
+	"hr":         nil,
+	"i":          nil,
+	"img":        {"src", "alt", "title", "width", "height"},
+	"kbd":        nil,
+	"li":         nil,
+	"mark":       nil,
+	"ol":         {"start"},
+	"p":          {"class"},
+	"pre":        {"class"},
+	"s":          nil,
+	"small":      nil,
+	"span":       {"class"},
+	"strong":     nil,
+	"sub":        nil,
+	"summary":    nil,
+	"sup":        nil,
+	"table":      nil,
+	"tbody":      nil,
+	"td":         {"align", "colspan", "rowspan"},
+	"th":         {"align", "colspan", "rowspan"},
+	"thead":      nil,
+	"tr":         nil,
+	"u":          nil,
+	"ul":         nil,
+}
+
+const (
+	tagNamePattern   = `[A-Za-z][A-Za-z0-9-]*`
+	attributePattern = `[A-Za-z_:][A-Za-z0-9_.:-]*(?:\s*=\s*(?:[^\s"'=<>` + "`" + `]+|'[^']*'|"[^"]*"))?`
+	openTagPattern   = `<` + tagNamePattern + `(?:\s+` + attributePattern + `)*\s*/?>`
+	closeTagPattern  = `</` + tagNamePattern + `\s*>`
+	commentPattern   = `<!--[\s\S]*?-->`
+)
+
+var (
+	// htmlTagRe matches a single inline HTML tag or comment at the start of
+	// its input.
+	htmlTagRe = regexp.MustCompile(`^(?:` + openTagPattern + `|` + closeTagPattern + `|` + commentPattern + `)`)
+	// anyTagRe finds every tag or comment inside a chunk of raw HTML.
+	anyTagRe    = regexp.MustCompile(openTagPattern + `|` + closeTagPattern + `|` + commentPattern)
+	tagNameRe   = regexp.MustCompile(`^</?(` + tagNamePattern + `)`)
+	attributeRe = regexp.MustCompile(`([A-Za-z_:][A-Za-z0-9_.:-]*)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+)))?`)
+	entityRe    = regexp.MustCompile(`^&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[A-Za-z][A-Za-z0-9]{1,31});`)
+)
+
+// htmlBlockTags are the tag names that start an HTML block which runs until
+// the next blank line.
+var htmlBlockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "base": true, "basefont": true,
+	"blockquote": true, "body": true, "caption": true, "center": true, "col": true,
+	"colgroup": true, "dd": true, "details": true, "dialog": true, "dir": true,
+	"div": true, "dl": true, "dt": true, "fieldset": true, "figcaption": true,
+	"figure": true, "footer": true, "form": true, "frame": true, "frameset": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"head": true, "header": true, "hr": true, "html": true, "iframe": true,
+	"legend": true, "li": true, "link": true, "main": true, "menu": true,
+	"menuitem": true, "nav": true, "noframes": true, "ol": true, "optgroup": true,
+	"option": true, "p": true, "param": true, "search": true, "section": true,
+	"summary": true, "table": true, "tbody": true, "td": true, "tfoot": true,
+	"th": true, "thead": true, "title": true, "tr": true, "track": true, "ul": true,
+}
+
+// rawTextTags hold content that is never Markdown, so an HTML block they
+// open runs to their closing tag, blank lines included.
+var rawTextTags = []string{"script", "pre", "style", "textarea"}
+
+// droppedTags are the raw text tags the sanitizer drops along with their
+// content, as it can't be shown as text. pre is kept as it is allowed.
+var droppedTags = []string{"script", "style", "textarea"}
+
+// htmlBlockEnd describes how an HTML block is terminated.
+type htmlBlockEnd int
+
+const (
+	htmlBlockNone      htmlBlockEnd = iota
+	htmlBlockRawText                // ends on the line closing a rawTextTag
+	htmlBlockComment                // ends on the line containing "-->"
+	htmlBlockBlankLine              // ends before the next blank line
+)
+
+// htmlBlockStart reports whether line, stripped of its indentation, opens an
+// HTML block and how that block ends. Tags outside htmlBlockTags only start
+// a block when they stand alone on the line and follow a blank line, so they
+// never interrupt a paragraph.
+func htmlBlockStart(line string, afterBlank bool) htmlBlockEnd {
+	if !strings.HasPrefix(line, "<") {
+		return htmlBlockNone
+	}
+	lower := strings.ToLower(line)
+	for _, tag := range rawTextTags {
+		if strings.HasPrefix(lower, "<"+tag) {
+			rest := lower[len(tag)+1:]
+			if rest == "" || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '>' {
+				return htmlBlockRawText
+			}
+		}
+	}
+	if strings.HasPrefix(line, "<!--") {
+		return htmlBlockComment
+	}
+	if m := tagNameRe.FindStringSubmatch(line); m != nil {
+		rest := line[len(m[0]):]
+		if htmlBlockTags[strings.ToLower(m[1])] &&
+			(rest == "" || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '>' || strings.HasPrefix(rest, "/>")) {
+			return htmlBlockBlankLine
+		}
+	}
+	if tag := htmlTagRe.FindString(line); afterBlank && tag != "" && !strings.HasPrefix(tag, "<!--") && isBlank(line[len(tag):]) {
+		return htmlBlockBlankLine
+	}
+	return htmlBlockNone
+}
+
+// htmlBlockEnds reports whether line is the last line of a block ending with
+// end.
+func htmlBlockEnds(line string, end htmlBlockEnd) bool {
+	switch end {
+	case htmlBlockRawText:
+		lower := strings.ToLower(line)
+		for _, tag := range rawTextTags {
+			if strings.Contains(lower, "</"+tag+">") {
+				return true
+			}
+		}
+	case htmlBlockComment:
+		return strings.Contains(line, "-->")
+	}
+	return false
+}
+
+var htmlEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&quot;",
+)
+
+// escapeHTML escapes the characters that are special inside HTML text and
+// attribute values.
+func escapeHTML(s string) string {
+	return htmlEscaper.Replace(s)
+}
+
+// escapeText escapes prose for HTML output. Unlike escapeHTML it leaves
+// entity references such as &amp; or &#8212; intact, since authors write them
+// on purpose.
+func escapeText(s string) string {
+	if !strings.ContainsAny(s, `&<>"`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '&':
+			if m := entityRe.FindString(s[i:]); m != "" {
+				sb.WriteString(m)
+				i += len(m) - 1
+			} else {
+				sb.WriteString("&amp;")
+			}
+		case '<':
+			sb.WriteString("&lt;")
+		case '>':
+			sb.WriteString("&gt;")
+		case '"':
+			sb.WriteString("&quot;")
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
+
+// sanitizeHTML rewrites every tag in raw against allow. Disallowed tags are
+// removed, along with the contents of the droppedTags, comments are dropped
+// and kept tags lose any attribute not listed for them. Text between tags is
+// escaped, so markup the tag patterns don't match can't get through.
+func sanitizeHTML(raw string, allow Allowlist) string {
+	var sb strings.Builder
+	skipUntil := "" // closing tag of a raw text element being dropped
+	last := 0
+	for _, loc := range anyTagRe.FindAllStringIndex(raw, -1) {
+		tag := raw[loc[0]:loc[1]]
+		if skipUntil == "" {
+			sb.WriteString(escapeText(raw[last:loc[0]]))
+		}
+		last = loc[1]
+
+		if strings.HasPrefix(tag, "<!--") {
+			continue
+		}
+		m := tagNameRe.FindStringSubmatch(tag)
+		name := strings.ToLower(m[1])
+		closing := strings.HasPrefix(tag, "</")
+
+		if skipUntil != "" {
+			if closing && name == skipUntil {
+				skipUntil = ""
+			}
+			continue
+		}
+		attrs, ok := allow[name]
+		if !ok {
+			if !closing && slices.Contains(droppedTags, name) && !strings.HasSuffix(tag, "/>") {
+				skipUntil = name
+			}
+			continue
+		}
+		if closing {
+			sb.WriteString("</" + name + ">")
+			continue
+		}
+		sb.WriteString(sanitizeTag(tag, name, attrs))
+	}
+	if skipUntil == "" {
+		sb.WriteString(escapeText(raw[last:]))
+	}
+	return sb.String()
+}
+
+// sanitizeTag rebuilds an opening tag keeping only the allowed attributes.
+func sanitizeTag(tag, name string, allowed []string) string {
+	var sb strings.Builder
+	sb.WriteString("<" + name)
+	body := strings.TrimSuffix(strings.TrimSuffix(tag[len(name)+1:], ">"), "/")
+	for _, m := range attributeRe.FindAllStringSubmatch(body, -1) {
+		attr := strings.ToLower(m[1])
+		if !slices.Contains(allowed, attr) {
+			continue
+		}
+		value := m[2] + m[3] + m[4]
+		if (attr == "href" || attr == "src" || attr == "cite") && !isSafeURL(value) {
+			continue
+		}
+		sb.WriteString(" " + attr + `="` + escapeHTML(value) + `"`)
+	}
+	if strings.HasSuffix(tag, "/>") {
+		sb.WriteString(" /")
+	}
+	sb.WriteString(">")
+	return sb.String()
+}
+
+// isSafeURL rejects URLs with schemes that can run script, such as
+// javascript: or data:.
+func isSafeURL(u string) bool {
+	u = strings.ToLower(strings.TrimSpace(u))
+	colon := strings.IndexByte(u, ':')
+	if colon < 0 || strings.ContainsAny(u[:colon], "/?#") {
+		return true // relative URL
+	}
+	switch u[:colon] {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
@@ -1,5 +1,12 @@
 package parser
 
+import (
+	"regexp"
+	"strings"
+
+	"github.com/iashyam/gossg/src/parser/ast"
+)
+
 type TokenType int
 
 const (
@@ -13,7 +20,16 @@ const (
 	BLANKLINE
 	HEADING
 	LIST
+	INDENT
 	MATH
+	MATH_DISPLAY
+	CODE_BLOCK
+	HTML_INLINE
+	HTML_BLOCK
+	TABLE
+	STRIKETHROUGH
+	HR
+	SETEXT
 )
 
 func (t TokenType) String() string {
@@ -36,8 +52,26 @@ func (t TokenType) String() string {
 		return "HEADING"
 	case LIST:
 		return "LIST"
+	case INDENT:
+		return "INDENT"
 	case MATH:
 		return "MATH"
+	case MATH_DISPLAY:
+		return "MATH_DISPLAY"
+	case CODE_BLOCK:
+		return "CODE_BLOCK"
+	case HTML_INLINE:
+		return "HTML_INLINE"
+	case HTML_BLOCK:
+		return "HTML_BLOCK"
+	case TABLE:
+		return "TABLE"
+	case STRIKETHROUGH:
+		return "STRIKETHROUGH"
+	case HR:
+		return "HR"
+	case SETEXT:
+		return "SETEXT"
 	default:
 		return "NONE"
 	}
@@ -54,8 +88,12 @@ const (
 )
 
 type Token struct {
-	Type  TokenType
-	value string
+	Type   TokenType
+	value  string
+	info   string // info string of a fenced code block, task state of a list item
+	indent int    // leading columns of the line a block token starts on
+	width  int    // columns taken by a list marker and the spaces after it
+	pos    ast.Position
 }
 
 type Lex struct {
@@ -64,16 +102,49 @@ type Lex struct {
 	char     byte //current character
 	prevChar byte //previous character
 	state    State
+	lists    []int // content columns of the open list items, innermost last
+	inline   bool  // lex inline content only, as in table cells
+	para     bool  // the previous line was paragraph text
+
+	line      int // current line number
+	lineStart int // offset of the current line
 }
 
 func NewLexer(input string) *Lex {
-	l := &Lex{input: input, pos: -1, state: StateText, prevChar: 0}
+	l := &Lex{input: input, pos: -1, state: StateText, prevChar: 0, line: 1}
 	l.ReadChar()
 	return l
 }
 
+// Tokenize lexes the whole input, ending with an EOF token.
+func Tokenize(input string) []Token {
+	return NewLexer(input).tokens()
+}
+
+// tokenizeInline lexes input without recognizing any block syntax.
+func tokenizeInline(input string) []Token {
+	l := NewLexer(input)
+	l.inline = true
+	return l.tokens()
+}
+
+func (l *Lex) tokens() []Token {
+	var tokens []Token
+	for {
+		token := l.ReadNextToken()
+		tokens = append(tokens, token)
+		if token.Type == EOF {
+			return tokens
+		}
+	}
+}
+
 func (l *Lex) ReadChar() {
 	l.prevChar = l.char
+	if l.char == '\n' {
+		l.line++
+		l.lineStart = l.pos + 1
+	}
 	l.pos++
 	if l.pos >= len(l.input) {
 		l.char = 0
@@ -89,6 +160,250 @@ func (l *Lex) PeekAhead() byte {
 	return l.input[l.pos+1]
 }
 
+// advance moves the lexer forward by n bytes.
+func (l *Lex) advance(n int) {
+	for i := 0; i < n; i++ {
+		l.ReadChar()
+	}
+}
+
+// restOfLine returns the input from the current position up to, but not
+// including, the next newline.
+func (l *Lex) restOfLine() string {
+	return lineAt(l.input, l.pos)
+}
+
+// lineIndent returns the width in columns of the leading whitespace of s,
+// counting tabs to the next multiple of four, and the bytes it occupies.
+func lineIndent(s string) (width, size int) {
+	for size < len(s) {
+		switch s[size] {
+		case ' ':
+			width++
+		case '\t':
+			width += 4 - width%4
+		default:
+			return width, size
+		}
+		size++
+	}
+	return width, size
+}
+
+// stripColumns removes up to n columns of leading whitespace from line.
+func stripColumns(line string, n int) string {
+	width := 0
+	for i := 0; i < len(line); i++ {
+		if width >= n {
+			return line[i:]
+		}
+		switch line[i] {
+		case ' ':
+			width++
+		case '\t':
+			width += 4 - width%4
+			if width > n {
+				return strings.Repeat(" ", width-n) + line[i+1:]
+			}
+		default:
+			return line[i:]
+		}
+	}
+	return ""
+}
+
+// lineAt returns the line of s starting at offset start.
+func lineAt(s string, start int) string {
+	if start >= len(s) {
+		return ""
+	}
+	if i := strings.IndexByte(s[start:], '\n'); i >= 0 {
+		return s[start : start+i]
+	}
+	return s[start:]
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// followsBlankLine reports whether the current line is the first line of the
+// input or comes right after a line holding nothing but whitespace.
+func (l *Lex) followsBlankLine() bool {
+	if l.pos == 0 {
+		return true
+	}
+	i := l.pos - 2 // skip the newline ending the previous line
+	for i >= 0 && (l.input[i] == ' ' || l.input[i] == '\t') {
+		i--
+	}
+	return i < 0 || l.input[i] == '\n'
+}
+
+// openingFence reports whether line (with indentation already removed) opens
+// a fenced code block, returning the fence character and its length.
+func openingFence(line string) (byte, int, bool) {
+	if len(line) < 3 || (line[0] != '`' && line[0] != '~') {
+		return 0, 0, false
+	}
+	fence := line[0]
+	n := 0
+	for n < len(line) && line[n] == fence {
+		n++
+	}
+	if n < 3 {
+		return 0, 0, false
+	}
+	// Backtick fences may not have backticks in their info string, otherwise
+	// the line is an inline code span.
+	if fence == '`' && strings.IndexByte(line[n:], '`') >= 0 {
+		return 0, 0, false
+	}
+	return fence, n, true
+}
+
+// isClosingFence reports whether line closes a fence of at least n fence
+// characters.
+func isClosingFence(line string, fence byte, n int) bool {
+	width, size := lineIndent(line)
+	if width > 3 {
+		return false
+	}
+	line = line[size:]
+	count := 0
+	for count < len(line) && line[count] == fence {
+		count++
+	}
+	return count >= n && isBlank(line[count:])
+}
+
+// FenceHandler lexes a ``` or ~~~ fenced code block. The token value is the
+// verbatim contents and info holds the info string following the fence.
+func (l *Lex) FenceHandler(indent, size int) Token {
+	l.advance(size)
+	fence, n, _ := openingFence(l.restOfLine())
+	l.advance(n)
+	info := strings.TrimSpace(l.restOfLine())
+	l.advance(len(l.restOfLine()))
+
+	var body strings.Builder
+	for l.char == '\n' {
+		l.ReadChar() // '\n'
+		line := l.restOfLine()
+		l.advance(len(line))
+		if isClosingFence(line, fence, n) {
+			break
+		}
+		body.WriteString(stripColumns(line, indent))
+		body.WriteByte('\n')
+	}
+	return Token{Type: CODE_BLOCK, value: body.String(), info: info, indent: indent}
+}
+
+// IndentedCodeHandler lexes a code block made of lines indented by at least
+// strip columns, which is four more than the enclosing list item's content.
+// Blank lines inside the block are kept, trailing ones are not.
+func (l *Lex) IndentedCodeHandler(indent, strip int) Token {
+	var body strings.Builder
+	for {
+		line := l.restOfLine()
+		l.advance(len(line))
+		body.WriteString(stripColumns(line, strip))
+		body.WriteByte('\n')
+
+		// Look past any blank lines for another indented line.
+		next, blanks := l.pos, 0
+		for next < len(l.input) && isBlank(lineAt(l.input, next+1)) {
+			next += 1 + len(lineAt(l.input, next+1))
+			blanks++
+		}
+		if next >= len(l.input) {
+			break
+		}
+		if width, _ := lineIndent(lineAt(l.input, next+1)); width < strip {
+			break
+		}
+		l.advance(next + 1 - l.pos)
+		body.WriteString(strings.Repeat("\n", blanks))
+	}
+	return Token{Type: CODE_BLOCK, value: body.String(), indent: indent}
+}
+
+// HTMLBlockHandler lexes a block of raw HTML lines, ending as described by
+// end.
+func (l *Lex) HTMLBlockHandler(end htmlBlockEnd) Token {
+	start := l.pos
+	for {
+		line := l.restOfLine()
+		l.advance(len(line))
+		if l.char == 0 || htmlBlockEnds(line, end) {
+			break
+		}
+		if end == htmlBlockBlankLine && isBlank(lineAt(l.input, l.pos+1)) {
+			break
+		}
+		l.ReadChar() // '\n'
+	}
+	return Token{Type: HTML_BLOCK, value: l.input[start:l.pos]}
+}
+
+// InlineHTMLHandler lexes a single tag or comment. A '<' that doesn't start
+// one is plain text.
+func (l *Lex) InlineHTMLHandler() Token {
+	tag := htmlTagRe.FindString(l.input[l.pos:])
+	if tag == "" {
+		l.ReadChar() // '<'
+		return Token{Type: TEXT, value: "<"}
+	}
+	l.advance(len(tag))
+	return Token{Type: HTML_INLINE, value: tag}
+}
+
+var hrRe = regexp.MustCompile(`^(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+
+// setextRe matches a line of = or - that may underline the paragraph above
+// it, making it a heading.
+var setextRe = regexp.MustCompile(`^(?:=+|-+)[ \t]*$`)
+
+// HRHandler lexes a thematic break such as --- or * * *.
+func (l *Lex) HRHandler() Token {
+	line := l.restOfLine()
+	l.advance(len(line))
+	return Token{Type: HR, value: strings.TrimSpace(line)}
+}
+
+// TableHandler lexes a GFM pipe table: the header row, the delimiter row and
+// every following row up to a blank line or the start of another block.
+func (l *Lex) TableHandler(indent int) Token {
+	start := l.pos
+	l.advance(len(l.restOfLine())) // header row
+	l.ReadChar()                   // '\n'
+	l.advance(len(l.restOfLine())) // delimiter row
+	for l.char == '\n' {
+		next := lineAt(l.input, l.pos+1)
+		if isBlank(next) || endsTable(next) {
+			break
+		}
+		l.ReadChar() // '\n'
+		l.advance(len(next))
+	}
+	return Token{Type: TABLE, value: l.input[start:l.pos], indent: indent}
+}
+
+// StrikethroughHandler lexes a ~ or ~~ strikethrough delimiter. Longer runs
+// of tildes are plain text.
+func (l *Lex) StrikethroughHandler() Token {
+	start := l.pos
+	for l.char == '~' {
+		l.ReadChar()
+	}
+	run := l.input[start:l.pos]
+	if len(run) > 2 {
+		return Token{Type: TEXT, value: run}
+	}
+	return Token{Type: STRIKETHROUGH, value: run}
+}
+
 func (l *Lex) QuoteHandler() Token {
 	l.ReadChar() // '>'
 	l.ReadChar() // ' '
@@ -133,7 +448,7 @@ func (l *Lex) ReadText() string {
 				break
 			}
 		} else {
-			if l.char == '*' || l.char == '$' || l.char == '\n' || l.char == '`' || l.char == '\\' {
+			if l.char == '*' || l.char == '$' || l.char == '\n' || l.char == '`' || l.char == '\\' || l.char == '<' || l.char == '~' {
 				break
 			}
 		}
@@ -142,27 +457,178 @@ func (l *Lex) ReadText() string {
 	return l.input[start:l.pos]
 }
 
-func (l *Lex) ListHandler() Token {
-	l.ReadChar() // '-'
-	l.ReadChar() // ' '
-	return Token{Type: LIST, value: "- "}
+// listMarker reports whether line, stripped of its indentation, starts with a
+// bullet (-, * or +) or ordered (1. or 1)) list marker and returns it.
+func listMarker(line string) (string, bool) {
+	n := 0
+	if line != "" && (line[0] == '-' || line[0] == '*' || line[0] == '+') {
+		n = 1
+	} else {
+		for n < len(line) && n < 9 && line[n] >= '0' && line[n] <= '9' {
+			n++
+		}
+		if n == 0 || n == len(line) || (line[n] != '.' && line[n] != ')') {
+			return "", false
+		}
+		n++
+	}
+	if n < len(line) && line[n] != ' ' && line[n] != '\t' {
+		return "", false
+	}
+	return line[:n], true
 }
 
+// canInterrupt reports whether an item with marker can start a list right
+// after a paragraph line. Only bullets and ordered lists starting at 1 can,
+// so wrapped prose starting with a number stays in its paragraph.
+func canInterrupt(marker string) bool {
+	ordered, start := listStart(marker)
+	return !ordered || start == 1
+}
+
+// ListHandler lexes a list item marker along with the indentation before it,
+// the spaces after it and a GFM task box ("[ ]" or "[x]") if there is one.
+func (l *Lex) ListHandler(indent, size int, marker string) Token {
+	l.advance(size + len(marker))
+	pad, padSize := lineIndent(l.restOfLine())
+	if pad == 0 || pad > 4 || padSize == len(l.restOfLine()) {
+		pad, padSize = 1, min(1, padSize)
+	}
+	l.advance(padSize)
+
+	token := Token{Type: LIST, value: marker, indent: indent, width: len(marker) + pad}
+	l.lists = append(l.lists, indent+token.width)
+
+	rest := l.restOfLine()
+	if len(rest) >= 3 && rest[0] == '[' && rest[2] == ']' && strings.ContainsRune(" xX", rune(rest[1])) &&
+		(len(rest) == 3 || rest[3] == ' ' || rest[3] == '\t') {
+		token.info = strings.ToLower(rest[1:2])
+		l.advance(3)
+	}
+	return token
+}
+
+// closeLists closes the open list items whose content starts to the right of
+// column indent.
+func (l *Lex) closeLists(indent int) {
+	for len(l.lists) > 0 && l.lists[len(l.lists)-1] > indent {
+		l.lists = l.lists[:len(l.lists)-1]
+	}
+}
+
+// listContent returns the content column of the innermost open list item.
+func (l *Lex) listContent() int {
+	if len(l.lists) == 0 {
+		return 0
+	}
+	return l.lists[len(l.lists)-1]
+}
+
+// MathHandler lexes TeX math opened by $ (inline) or $$ (display). A dollar
+// sign that can't open math or is never closed is plain text, so prices like
+// "$5 and $10" survive.
 func (l *Lex) MathHandler() Token {
+	rest := l.input[l.pos:]
+	if strings.HasPrefix(rest, "$$") {
+		if end := findMathCloser(rest[2:], "$$"); end >= 0 {
+			l.advance(2 + end + 2)
+			return Token{Type: MATH_DISPLAY, value: rest[2 : 2+end]}
+		}
+		l.advance(2)
+		return Token{Type: TEXT, value: "$$"}
+	}
+	if end := findDollarCloser(rest[1:]); end >= 0 {
+		l.advance(1 + end + 1)
+		return Token{Type: MATH, value: rest[1 : 1+end]}
+	}
 	l.ReadChar() // '$'
-	start := l.pos
-	for l.char != 0 && l.char != '$' {
-		l.ReadChar()
+	return Token{Type: TEXT, value: "$"}
+}
+
+// BackslashMathHandler lexes math delimited by \(...\) (inline), \[...\]
+// (display) or a \begin{env}...\end{env} environment, which MathJax
+// processes as display math on its own. It reports false when the backslash
+// doesn't open closed math.
+func (l *Lex) BackslashMathHandler() (Token, bool) {
+	rest := l.input[l.pos:]
+	switch {
+	case strings.HasPrefix(rest, `\(`):
+		if end := findMathCloser(rest[2:], `\)`); end >= 0 {
+			l.advance(2 + end + 2)
+			return Token{Type: MATH, value: rest[2 : 2+end]}, true
+		}
+	case strings.HasPrefix(rest, `\[`):
+		if end := findMathCloser(rest[2:], `\]`); end >= 0 {
+			l.advance(2 + end + 2)
+			return Token{Type: MATH_DISPLAY, value: rest[2 : 2+end]}, true
+		}
+	case strings.HasPrefix(rest, `\begin{`):
+		name, _, ok := strings.Cut(rest[len(`\begin{`):], "}")
+		if !ok || name == "" || strings.ContainsAny(name, " \n") {
+			break
+		}
+		closer := `\end{` + name + `}`
+		if end := strings.Index(rest, closer); end >= 0 {
+			l.advance(end + len(closer))
+			return Token{Type: MATH_DISPLAY, value: rest[:end+len(closer)], info: name}, true
+		}
+	}
+	return Token{}, false
+}
+
+// findMathCloser returns the offset of closer in s, skipping backslash
+// escapes, or -1 if a blank line comes first.
+func findMathCloser(s, closer string) int {
+	for i := 0; i < len(s); i++ {
+		if strings.HasPrefix(s[i:], closer) {
+			return i
+		}
+		if strings.HasPrefix(s[i:], "\n\n") {
+			return -1
+		}
+		if s[i] == '\\' {
+			i++
+		}
 	}
-	if l.char == '$' {
-		l.ReadChar() // consume closing '$'
-		return Token{Type: MATH, value: l.input[start : l.pos-1]}
+	return -1
+}
+
+// findDollarCloser returns the offset in s of the $ closing inline math, or
+// -1. Like Pandoc, math may not start or end with whitespace and the closing
+// $ may not be followed by a digit.
+func findDollarCloser(s string) int {
+	if s == "" || s[0] == ' ' || s[0] == '\t' || s[0] == '\n' || s[0] == '$' {
+		return -1
+	}
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "\n\n"):
+			return -1
+		case s[i] == '\\':
+			i++
+		case s[i] == '$':
+			prev := s[i-1]
+			next := byte(0)
+			if i+1 < len(s) {
+				next = s[i+1]
+			}
+			if prev != ' ' && prev != '\t' && prev != '\n' && (next < '0' || next > '9') {
+				return i
+			}
+		}
 	}
-	return Token{Type: MATH, value: l.input[start:l.pos]}
+	return -1
 }
 
+// ReadNextToken lexes the next token and records where it starts.
 func (l *Lex) ReadNextToken() Token {
+	pos := ast.Position{Offset: l.pos, Line: l.line, Column: l.pos - l.lineStart + 1}
+	token := l.readToken()
+	token.pos = pos
+	return token
+}
 
+func (l *Lex) readToken() Token {
 	switch l.state {
 	case StateText, StateItalic, StateBold, StateQuote:
 		if l.char == 0 {
@@ -170,9 +636,16 @@ func (l *Lex) ReadNextToken() Token {
 		}
 
 		if l.char == '\\' {
+			if token, ok := l.BackslashMathHandler(); ok {
+				return token
+			}
 			l.ReadChar()
 			char := l.char
 			l.ReadChar()
+			if char == '$' {
+				// Keep the backslash, so MathJax doesn't read a dollar as math
+				return Token{Type: TEXT, value: `\$`}
+			}
 			return Token{Type: TEXT, value: string(char)}
 		}
 
@@ -187,7 +660,56 @@ func (l *Lex) ReadNextToken() Token {
 			return Token{Type: NEWLINE, value: "NEWLINE"}
 		}
 
-		isAtLineStart := l.prevChar == '\n' || l.prevChar == 0
+		isAtLineStart := !l.inline && (l.prevChar == '\n' || l.prevChar == 0)
+
+		if isAtLineStart {
+			line := l.restOfLine()
+			indent, size := lineIndent(line)
+			rest := line[size:]
+			afterBlank := l.followsBlankLine()
+			para := l.para && !afterBlank && len(l.lists) == 0
+			l.para = false
+			if afterBlank && rest != "" {
+				l.closeLists(indent)
+			}
+			base := l.listContent()
+			if indent-base >= 4 && rest != "" && afterBlank {
+				return l.IndentedCodeHandler(indent, base+4)
+			}
+			if indent-base < 4 {
+				if _, _, ok := openingFence(rest); ok {
+					l.closeLists(indent)
+					return l.FenceHandler(indent, size)
+				}
+				if end := htmlBlockStart(rest, afterBlank); end != htmlBlockNone {
+					l.closeLists(indent)
+					return l.HTMLBlockHandler(end)
+				}
+				if !afterBlank && setextRe.MatchString(rest) {
+					l.closeLists(indent)
+					l.advance(len(line))
+					return Token{Type: SETEXT, value: strings.TrimSpace(rest), indent: indent}
+				}
+				if hrRe.MatchString(rest) {
+					l.closeLists(indent)
+					l.advance(size)
+					return l.HRHandler()
+				}
+				if isTableStart(rest, lineAt(l.input, l.pos+len(line)+1)) {
+					l.closeLists(indent)
+					return l.TableHandler(indent)
+				}
+				if marker, ok := listMarker(rest); ok && (!para || canInterrupt(marker)) {
+					l.closeLists(indent)
+					return l.ListHandler(indent, size, marker)
+				}
+			}
+			l.para = rest != ""
+			if size > 0 && rest != "" {
+				l.advance(size)
+				return Token{Type: INDENT, value: line[:size], indent: indent}
+			}
+		}
 
 		if isAtLineStart && l.char == '#' {
 			count := 0
@@ -200,14 +722,16 @@ func (l *Lex) ReadNextToken() Token {
 			}
 
 			if count > 0 && count <= 6 && l.pos+count < len(l.input) && l.input[l.pos+count] == ' ' {
+				l.closeLists(0)
+				l.para = false
 				return l.HeadingHandler()
 			}
 		}
 
-		if isAtLineStart && (l.char == '-' || l.char == '*' || l.char == '+') && l.PeekAhead() == ' ' {
-			return l.ListHandler()
-		}
 		if l.char == '>' && l.PeekAhead() == ' ' {
+			if isAtLineStart {
+				l.para = false
+			}
 			l.state = StateQuote
 			return l.QuoteHandler()
 		} else if l.char == '`' {
@@ -221,6 +745,10 @@ func (l *Lex) ReadNextToken() Token {
 			return l.ItalicHandler()
 		} else if l.char == '$' {
 			return l.MathHandler()
+		} else if l.char == '<' {
+			return l.InlineHTMLHandler()
+		} else if l.char == '~' {
+			return l.StrikethroughHandler()
 		} else {
 			value := l.ReadText()
 			return Token{Type: TEXT, value: value}
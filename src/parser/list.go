@@ -0,0 +1,121 @@
+package parser
+
+import (
+	"strconv"
+
+	"github.com/iashyam/gossg/src/parser/ast"
+)
+
+// parseList parses a bullet or ordered list whose items start at or right
+// of column minIndent, along with any lists nested in its items.
+func (p *Parser) parseList(parent ast.Node, minIndent int) {
+	first := p.tokens[p.pos]
+	list := &ast.List{Marker: first.value[len(first.value)-1], Tight: true}
+	list.Ordered, list.Start = listStart(first.value)
+	appendNode(parent, list, first)
+
+	for {
+		content, loose := p.parseListItem(list)
+		if loose {
+			list.Tight = false
+		}
+
+		// The next item must sit left of this item's content, or it would be
+		// nested inside it, and use the same kind of marker.
+		save := p.pos
+		next, blank := p.skipBlankLines()
+		if next.Type != LIST || next.indent < minIndent || next.indent >= content || !sameListType(first, next) {
+			p.pos = save
+			break
+		}
+		if blank {
+			list.Tight = false
+		}
+	}
+}
+
+// parseListItem parses one item and everything indented under it into list.
+// It returns the column the item's content starts at and whether blank lines
+// separate its blocks.
+func (p *Parser) parseListItem(list *ast.List) (int, bool) {
+	marker := p.tokens[p.pos]
+	p.pos++
+	content := marker.indent + marker.width
+	item := &ast.ListItem{Task: taskState(marker.info)}
+	appendNode(list, item, marker)
+
+	para := &ast.Paragraph{}
+	p.parseInline(para)
+	if len(para.Children()) > 0 || item.Task != ast.NoTask {
+		appendNode(item, p.setextHeading(para, content), marker)
+	}
+
+	loose := false
+	for p.pos < len(p.tokens) {
+		save := p.pos
+		next, blank := p.skipBlankLines()
+		if next.indent < content {
+			p.pos = save
+			break
+		}
+
+		switch next.Type {
+		case LIST:
+			p.parseList(item, content)
+		case CODE_BLOCK:
+			p.parseCodeBlock(item)
+		case INDENT:
+			p.pos++
+			p.parseBlock(item)
+		default:
+			p.pos = save
+		}
+		if p.pos == save {
+			break
+		}
+		loose = loose || blank
+	}
+	return content, loose
+}
+
+// skipBlankLines moves past line breaks and reports the token after them and
+// whether any of them was a blank line.
+func (p *Parser) skipBlankLines() (Token, bool) {
+	blank := false
+	for p.pos < len(p.tokens) && (p.tokens[p.pos].Type == BLANKLINE || p.tokens[p.pos].Type == NEWLINE) {
+		blank = blank || p.tokens[p.pos].Type == BLANKLINE
+		p.pos++
+	}
+	if p.pos >= len(p.tokens) {
+		return Token{Type: EOF}, blank
+	}
+	return p.tokens[p.pos], blank
+}
+
+// taskState converts the task box recorded by the lexer.
+func taskState(info string) ast.TaskState {
+	switch info {
+	case " ":
+		return ast.TaskUnchecked
+	case "x":
+		return ast.TaskChecked
+	}
+	return ast.NoTask
+}
+
+// listStart reports whether marker belongs to an ordered list and the number
+// it starts at.
+func listStart(marker string) (bool, int) {
+	last := marker[len(marker)-1]
+	if last != '.' && last != ')' {
+		return false, 0
+	}
+	start, _ := strconv.Atoi(marker[:len(marker)-1])
+	return true, start
+}
+
+// sameListType reports whether two item markers can share a list: the same
+// bullet character, or ordered markers with the same delimiter.
+func sameListType(a, b Token) bool {
+	return a.value[len(a.value)-1] == b.value[len(b.value)-1]
+}
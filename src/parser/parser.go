@@ -1,8 +1,9 @@
 package parser
 
 import (
-	"fmt"
 	"strings"
+
+	"github.com/iashyam/gossg/src/parser/ast"
 )
 
 type Parser struct {
@@ -14,82 +15,142 @@ func NewParser(tokens []Token) *Parser {
 	return &Parser{tokens: tokens, pos: 0}
 }
 
-func (p *Parser) Parse() string {
-	var sb strings.Builder
+// Parse builds the document tree for the parser's tokens.
+func (p *Parser) Parse() *ast.Document {
+	doc := &ast.Document{}
+	if len(p.tokens) > 0 {
+		doc.SetPos(p.tokens[0].pos)
+	}
 
 	for p.pos < len(p.tokens) {
-		token := p.tokens[p.pos]
-		switch token.Type {
-		case HEADING:
-			p.parseHeading(&sb)
-		case LIST:
-			p.parseList(&sb)
-		case QUOTE:
-			p.parseQuote(&sb)
-		case BLANKLINE:
-			p.pos++ // Skip blanklines between blocks
-		case EOF:
+		p.parseBlock(doc)
+	}
+	return doc
+}
+
+// ToHTML parses Markdown input and renders it to HTML.
+func ToHTML(input string, opts ...HTMLOption) string {
+	return NewHTMLRenderer(opts...).Render(NewParser(Tokenize(input)).Parse())
+}
+
+// parseBlock parses the block starting at the current token into parent.
+func (p *Parser) parseBlock(parent ast.Node) {
+	token := p.tokens[p.pos]
+	switch token.Type {
+	case HEADING:
+		p.parseHeading(parent)
+	case LIST:
+		p.parseList(parent, 0)
+	case QUOTE:
+		p.parseQuote(parent)
+	case CODE_BLOCK:
+		p.parseCodeBlock(parent)
+	case HTML_BLOCK:
+		appendNode(parent, &ast.HTMLBlock{Literal: token.value}, token)
+		p.pos++
+	case TABLE:
+		p.parseTable(parent)
+	case HR:
+		appendNode(parent, &ast.ThematicBreak{}, token)
+		p.pos++
+	case SETEXT:
+		// An underline with no paragraph above it is a thematic break or text
+		if hrRe.MatchString(token.value) {
+			appendNode(parent, &ast.ThematicBreak{}, token)
 			p.pos++
-		default:
-			p.parseParagraph(&sb)
+			return
 		}
+		p.tokens[p.pos].Type = TEXT
+		p.parseParagraph(parent)
+	case BLANKLINE, NEWLINE:
+		p.pos++ // Skip blanklines between blocks
+	case EOF:
+		p.pos++
+	default:
+		p.parseParagraph(parent)
 	}
-	return sb.String()
 }
 
-func (p *Parser) parseHeading(sb *strings.Builder) {
+// appendNode places n at token's position and adds it to parent.
+func appendNode(parent, n ast.Node, token Token) {
+	n.SetPos(token.pos)
+	parent.AppendChild(n)
+}
+
+func (p *Parser) parseHeading(parent ast.Node) {
 	token := p.tokens[p.pos]
-	level := strings.Count(token.value, "#")
-	sb.WriteString(fmt.Sprintf("<h%d>", level))
+	heading := &ast.Heading{Level: strings.Count(token.value, "#")}
+	appendNode(parent, heading, token)
 	p.pos++
 
-	p.parseInline(sb)
-
-	sb.WriteString(fmt.Sprintf("</h%d>\n", level))
+	p.parseInline(heading)
 }
 
-func (p *Parser) parseList(sb *strings.Builder) {
-	sb.WriteString("<ul>\n")
-	for p.pos < len(p.tokens) && p.tokens[p.pos].Type == LIST {
-		p.pos++ // consume list marker
-		sb.WriteString("<li>")
-		p.parseInline(sb)
-		sb.WriteString("</li>\n")
-	}
-	sb.WriteString("</ul>\n")
+// parseCodeBlock adds a fenced or indented code block.
+func (p *Parser) parseCodeBlock(parent ast.Node) {
+	token := p.tokens[p.pos]
+	p.pos++
+	appendNode(parent, &ast.CodeBlock{Info: token.info, Literal: token.value}, token)
 }
 
-func (p *Parser) parseQuote(sb *strings.Builder) {
-	sb.WriteString("<blockquote>\n<p>")
-	// Quote might span multiple lines with > markers or just be a block?
-	// Lexer emits QUOTE for '> '.
-	// We can treat consecutive QUOTE lines as one blockquote.
+func (p *Parser) parseQuote(parent ast.Node) {
+	quote := &ast.Blockquote{}
+	appendNode(parent, quote, p.tokens[p.pos])
+	para := &ast.Paragraph{}
+	appendNode(quote, para, p.tokens[p.pos])
 
+	// Consecutive quoted lines form a single paragraph.
 	for p.pos < len(p.tokens) && p.tokens[p.pos].Type == QUOTE {
+		if p.pos > 0 && p.tokens[p.pos-1].Type == NEWLINE {
+			appendNode(para, &ast.SoftBreak{}, p.tokens[p.pos-1])
+		}
 		p.pos++ // consume >
-		p.parseInline(sb)
-		// If parseInline consumed NEWLINE, next might be QUOTE again.
-		// We insert a space if joining lines?
-		// parseInline converts NEWLINE to space.
+		p.parseInline(para)
 	}
-	sb.WriteString("</p>\n</blockquote>\n")
 }
 
-func (p *Parser) parseParagraph(sb *strings.Builder) {
-	sb.WriteString("<p>")
-	p.parseInline(sb)
-	sb.WriteString("</p>\n")
+// parseParagraph adds a paragraph, or a heading when a setext underline
+// (=== or ---) follows it.
+func (p *Parser) parseParagraph(parent ast.Node) {
+	token := p.tokens[p.pos]
+	para := &ast.Paragraph{}
+	p.parseInline(para)
+	appendNode(parent, p.setextHeading(para, 0), token)
+}
+
+// setextHeading returns para as a heading when a setext underline at column
+// minIndent or right of it follows, consuming the underline, and para
+// otherwise.
+func (p *Parser) setextHeading(para *ast.Paragraph, minIndent int) ast.Node {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].Type != SETEXT || p.tokens[p.pos].indent < minIndent {
+		return para
+	}
+	level := 2
+	if p.tokens[p.pos].value[0] == '=' {
+		level = 1
+	}
+	heading := &ast.Heading{Level: level}
+	for _, child := range para.Children() {
+		heading.AppendChild(child)
+	}
+	p.pos++
+	return heading
 }
 
 // parseInline consumes tokens until a block separator (BLANKLINE) or block starter (HEADING, LIST, QUOTE) is found.
 // It effectively handles "inline" content which can span multiple lines (paragraphs).
-func (p *Parser) parseInline(sb *strings.Builder) {
-	p.parseUntil(sb, EOF)
+func (p *Parser) parseInline(parent ast.Node) {
+	p.parseUntil(parent, EOF)
+}
+
+// isBlockStart reports whether a token of type t opens a new block.
+func isBlockStart(t TokenType) bool {
+	return t == HEADING || t == LIST || t == QUOTE || t == CODE_BLOCK || t == HTML_BLOCK || t == TABLE || t == HR || t == SETEXT
 }
 
 // parseUntil consumes tokens until endTokenType is found (if not EOF).
 // If endTokenType is EOF, it runs until block boundaries.
-func (p *Parser) parseUntil(sb *strings.Builder, endTokenType TokenType) {
+func (p *Parser) parseUntil(parent ast.Node, endTokenType TokenType) {
 	for p.pos < len(p.tokens) {
 		token := p.tokens[p.pos]
 
@@ -105,50 +166,44 @@ func (p *Parser) parseUntil(sb *strings.Builder, endTokenType TokenType) {
 		// Check for block starters only if we are at start of line (implied by previous NEWLINE consumed?
 		// Actually Lexer only emits HEADING/LIST/QUOTE at start of line.
 		// So if we see them here, it means we are at start of line.
-		if token.Type == HEADING || token.Type == LIST || token.Type == QUOTE {
+		if isBlockStart(token.Type) {
 			return
 		}
 
+		if token.Type == INDENT {
+			p.pos++ // continuation lines lose their indentation
+			continue
+		}
+
 		if token.Type == NEWLINE {
-			sb.WriteString(" ")
 			p.pos++
+			if p.pos < len(p.tokens) && isBlockStart(p.tokens[p.pos].Type) {
+				return // the next line starts a new block
+			}
+			appendNode(parent, &ast.SoftBreak{}, token)
 			continue
 		}
 
 		switch token.Type {
 		case TEXT:
-			sb.WriteString(token.value)
+			appendNode(parent, &ast.Text{Literal: token.value}, token)
 			p.pos++
-		case BOLD:
-			sb.WriteString("<strong>")
+		case HTML_INLINE:
+			appendNode(parent, &ast.RawHTML{Literal: token.value}, token)
 			p.pos++
-			p.parseUntil(sb, BOLD)
-			sb.WriteString("</strong>")
-			if p.pos < len(p.tokens) && p.tokens[p.pos].Type == BOLD {
-				p.pos++
-			}
+		case BOLD:
+			p.parseSpan(parent, &ast.Emphasis{Level: 2}, BOLD)
 		case ITALIC:
-			sb.WriteString("<em>")
-			p.pos++
-			p.parseUntil(sb, ITALIC)
-			sb.WriteString("</em>")
-			if p.pos < len(p.tokens) && p.tokens[p.pos].Type == ITALIC {
-				p.pos++
-			}
+			p.parseSpan(parent, &ast.Emphasis{Level: 1}, ITALIC)
+		case STRIKETHROUGH:
+			p.parseSpan(parent, &ast.Strikethrough{}, STRIKETHROUGH)
 		case INLINE_CODE:
-			sb.WriteString("<code>")
-			p.pos++
-			p.parseUntil(sb, INLINE_CODE)
-			sb.WriteString("</code>")
-			if p.pos < len(p.tokens) && p.tokens[p.pos].Type == INLINE_CODE {
-				p.pos++
-			}
+			p.parseCode(parent)
 		case MATH:
-			// Strip $ if present in value? Lexer keeps them now?
-			// Lexer MathHandler returns content between $.
-			// Wait, prior fix: "return Token{Type: MATH, value: l.input[start : l.pos-1]}"
-			// This strips the $.
-			sb.WriteString(fmt.Sprintf("<math>%s</math>", token.value))
+			appendNode(parent, &ast.Math{Literal: token.value}, token)
+			p.pos++
+		case MATH_DISPLAY:
+			appendNode(parent, &ast.Math{Literal: token.value, Display: true, Environment: token.info}, token)
 			p.pos++
 		default:
 			// Should not happen for handled types.
@@ -156,3 +211,32 @@ func (p *Parser) parseUntil(sb *strings.Builder, endTokenType TokenType) {
 		}
 	}
 }
+
+// parseSpan adds span and fills it with the inline content up to the
+// closing delimiter of type closer.
+func (p *Parser) parseSpan(parent, span ast.Node, closer TokenType) {
+	appendNode(parent, span, p.tokens[p.pos])
+	p.pos++
+	p.parseUntil(span, closer)
+	if p.pos < len(p.tokens) && p.tokens[p.pos].Type == closer {
+		p.pos++
+	}
+}
+
+// parseCode adds an inline code span. The lexer emits its contents as plain
+// text, whatever characters it holds.
+func (p *Parser) parseCode(parent ast.Node) {
+	code := &ast.Code{}
+	appendNode(parent, code, p.tokens[p.pos])
+	p.pos++
+
+	var literal strings.Builder
+	for p.pos < len(p.tokens) && p.tokens[p.pos].Type == TEXT {
+		literal.WriteString(p.tokens[p.pos].value)
+		p.pos++
+	}
+	code.Literal = literal.String()
+	if p.pos < len(p.tokens) && p.tokens[p.pos].Type == INLINE_CODE {
+		p.pos++
+	}
+}
@@ -0,0 +1,480 @@
+package parser
+
+import "testing"
+
+// render lexes and parses input into HTML.
+func render(input string, opts ...HTMLOption) string {
+	return ToHTML(input, opts...)
+}
+
+func TestParseCodeBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Backtick fence with language",
+			input:    "```python\nimport numpy as np\nx = 2 * 3\n```",
+			expected: "<pre><code class=\"language-python\">import numpy as np\nx = 2 * 3\n</code></pre>\n",
+		},
+		{
+			name:     "Tilde fence",
+			input:    "~~~\n**not bold**\n~~~",
+			expected: "<pre><code>**not bold**\n</code></pre>\n",
+		},
+		{
+			name:     "Longer closing fence and nested shorter fence",
+			input:    "````md\n```\ninner\n```\n`````",
+			expected: "<pre><code class=\"language-md\">```\ninner\n```\n</code></pre>\n",
+		},
+		{
+			name:     "Info string with attributes",
+			input:    "``` go {linenos=true}\nfmt.Println(\"hi\")\n```",
+			expected: "<pre><code class=\"language-go\">fmt.Println(&quot;hi&quot;)\n</code></pre>\n",
+		},
+		{
+			name:     "Contents are HTML escaped",
+			input:    "```html\n<a href=\"x\">&amp;</a>\n```",
+			expected: "<pre><code class=\"language-html\">&lt;a href=&quot;x&quot;&gt;&amp;amp;&lt;/a&gt;\n</code></pre>\n",
+		},
+		{
+			name:     "Unclosed fence runs to the end",
+			input:    "```\n$x$ and `y`",
+			expected: "<pre><code>$x$ and `y`\n</code></pre>\n",
+		},
+		{
+			name:     "Indented fence strips its indentation",
+			input:    "  ```\n  a\n    b\n  ```",
+			expected: "<pre><code>a\n  b\n</code></pre>\n",
+		},
+		{
+			name:     "Indented code block",
+			input:    "    for i in range(3):\n        print(i)",
+			expected: "<pre><code>for i in range(3):\n    print(i)\n</code></pre>\n",
+		},
+		{
+			name:     "Indented code keeps inner blank lines only",
+			input:    "    a\n\n    b\n\n\ntext",
+			expected: "<pre><code>a\n\nb\n</code></pre>\n<p>text</p>\n",
+		},
+		{
+			name:     "Indented lines continue a paragraph",
+			input:    "text\n    more",
+			expected: "<p>text more</p>\n",
+		},
+		{
+			name:     "Code block between paragraphs",
+			input:    "before\n```\ncode\n```\nafter",
+			expected: "<p>before</p>\n<pre><code>code\n</code></pre>\n<p>after</p>\n",
+		},
+		{
+			name:     "Inline code is unaffected",
+			input:    "use `x` here",
+			expected: "<p>use <code>x</code> here</p>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := render(tt.input); got != tt.expected {
+				t.Errorf("render() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseHTML(t *testing.T) {
+	colab := `<a href="https://colab.research.google.com/x.ipynb" target="_parent"><img src="https://colab.research.google.com/assets/colab-badge.svg" alt="Open In Colab"/></a>`
+
+	tests := []struct {
+		name     string
+		input    string
+		policy   HTMLPolicy
+		expected string
+	}{
+		{
+			name:     "Text is escaped",
+			input:    `if a < b && c > d say "hi"`,
+			expected: "<p>if a &lt; b &amp;&amp; c &gt; d say &quot;hi&quot;</p>\n",
+		},
+		{
+			name:     "Entity references are kept",
+			input:    "Tom &amp; Jerry &#8212; &copy; &nope",
+			expected: "<p>Tom &amp; Jerry &#8212; &copy; &amp;nope</p>\n",
+		},
+		{
+			name:     "Escaped angle bracket",
+			input:    `\<b>`,
+			expected: "<p>&lt;b&gt;</p>\n",
+		},
+		{
+			name:     "Inline code escapes entities",
+			input:    "`&amp; <br>`",
+			expected: "<p><code>&amp;amp; &lt;br&gt;</code></p>\n",
+		},
+		{
+			name:     "Inline tags pass through",
+			input:    "H<sub>2</sub>O is *wet*<br>",
+			expected: "<p>H<sub>2</sub>O is <em>wet</em><br></p>\n",
+		},
+		{
+			name:     "Colab badge paragraph",
+			input:    colab + "\n\n## Shyam Sunder",
+			expected: "<p>" + colab + "</p>\n<h2>Shyam Sunder</h2>\n",
+		},
+		{
+			name:     "Block HTML is not Markdown",
+			input:    "<div class=\"note\">\n*raw*\n</div>\n\n*md*",
+			expected: "<div class=\"note\">\n*raw*\n</div>\n<p><em>md</em></p>\n",
+		},
+		{
+			name:     "Script block ends at its closing tag",
+			input:    "<script>\nlet a = 1;\n\nlet b = a < 2;\n</script>\ntext",
+			expected: "<script>\nlet a = 1;\n\nlet b = a < 2;\n</script>\n<p>text</p>\n",
+		},
+		{
+			name:     "Comment block",
+			input:    "<!-- draft\n\nnotes -->",
+			expected: "<!-- draft\n\nnotes -->\n",
+		},
+		{
+			name:     "Lone tag line after a blank line is a block",
+			input:    "<span>\n\nafter",
+			expected: "<span>\n<p>after</p>\n",
+		},
+		{
+			name:     "Escape policy",
+			input:    "a <b>bold</b> move\n\n<div>x</div>",
+			policy:   HTMLEscape,
+			expected: "<p>a &lt;b&gt;bold&lt;/b&gt; move</p>\n&lt;div&gt;x&lt;/div&gt;\n",
+		},
+		{
+			name:     "Sanitize keeps allowed tags and attributes",
+			input:    colab,
+			policy:   HTMLSanitize,
+			expected: "<p><a href=\"https://colab.research.google.com/x.ipynb\" target=\"_parent\"><img src=\"https://colab.research.google.com/assets/colab-badge.svg\" alt=\"Open In Colab\" /></a></p>\n",
+		},
+		{
+			name:     "Sanitize drops unsafe markup",
+			input:    "<div onclick=\"steal()\"><a href=\"javascript:alert(1)\">x</a><script>alert(1)</script><!-- c --></div>",
+			policy:   HTMLSanitize,
+			expected: "<div><a>x</a></div>\n",
+		},
+		{
+			name:     "Sanitize drops unknown inline tags",
+			input:    "click <button onclick=\"x()\">me</button>",
+			policy:   HTMLSanitize,
+			expected: "<p>click me</p>\n",
+		},
+		{
+			name:     "Sanitize escapes tags it can't parse",
+			input:    "<div>\n<svg/onload=alert(1)>\n</div>",
+			policy:   HTMLSanitize,
+			expected: "<div>\n&lt;svg/onload=alert(1)&gt;\n</div>\n",
+		},
+		{
+			name:     "Sanitize escapes unclosed tags",
+			input:    "<div>\n<img src=x onerror=alert(1)//</div>",
+			policy:   HTMLSanitize,
+			expected: "<div>\n&lt;img src=x onerror=alert(1)//</div>\n",
+		},
+		{
+			name:     "Sanitize keeps pre content",
+			input:    "<pre>\na &amp; b < c\n</pre>",
+			policy:   HTMLSanitize,
+			expected: "<pre>\na &amp; b &lt; c\n</pre>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := render(tt.input, WithHTMLPolicy(tt.policy)); got != tt.expected {
+				t.Errorf("render() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseLists(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Tight bullet list",
+			input:    "- one\n- two\n- three",
+			expected: "<ul>\n<li>one</li>\n<li>two</li>\n<li>three</li>\n</ul>\n",
+		},
+		{
+			name:     "Other bullet characters",
+			input:    "* a\n* b\n\n+ c",
+			expected: "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n<ul>\n<li>c</li>\n</ul>\n",
+		},
+		{
+			name:     "Ordered list",
+			input:    "1. first\n2. second",
+			expected: "<ol>\n<li>first</li>\n<li>second</li>\n</ol>\n",
+		},
+		{
+			name:     "Ordered list with start number and parenthesis",
+			input:    "3) x\n4) y",
+			expected: "<ol start=\"3\">\n<li>x</li>\n<li>y</li>\n</ol>\n",
+		},
+		{
+			name:     "Changing delimiter starts a new list",
+			input:    "1. a\n1) b",
+			expected: "<ol>\n<li>a</li>\n</ol>\n<ol>\n<li>b</li>\n</ol>\n",
+		},
+		{
+			name:     "Nested lists",
+			input:    "- a\n  - b\n    1. c\n- d",
+			expected: "<ul>\n<li>a\n<ul>\n<li>b\n<ol>\n<li>c</li>\n</ol>\n</li>\n</ul>\n</li>\n<li>d</li>\n</ul>\n",
+		},
+		{
+			name:     "Loose list",
+			input:    "1. a\n\n2. b\n   - c",
+			expected: "<ol>\n<li>\n<p>a</p>\n</li>\n<li>\n<p>b</p>\n<ul>\n<li>c</li>\n</ul>\n</li>\n</ol>\n",
+		},
+		{
+			name:     "Multi-paragraph item",
+			input:    "- first para\n\n  second *para*\n- next",
+			expected: "<ul>\n<li>\n<p>first para</p>\n<p>second <em>para</em></p>\n</li>\n<li>\n<p>next</p>\n</li>\n</ul>\n",
+		},
+		{
+			name:     "Lazy continuation line",
+			input:    "- a long\nline\n- b",
+			expected: "<ul>\n<li>a long line</li>\n<li>b</li>\n</ul>\n",
+		},
+		{
+			name:     "Unindented paragraph after a blank line ends the list",
+			input:    "- a\n\nafter",
+			expected: "<ul>\n<li>a</li>\n</ul>\n<p>after</p>\n",
+		},
+		{
+			name:     "Only lists starting at 1 interrupt a paragraph",
+			input:    "foo\n2. bar\n\nfoo\n1. bar\n2. baz",
+			expected: "<p>foo 2. bar</p>\n<p>foo</p>\n<ol>\n<li>bar</li>\n<li>baz</li>\n</ol>\n",
+		},
+		{
+			name:     "Code block inside an item",
+			input:    "- run:\n  ```sh\n  go test\n  ```\n- done",
+			expected: "<ul>\n<li>run:\n<pre><code class=\"language-sh\">go test\n</code></pre>\n</li>\n<li>done</li>\n</ul>\n",
+		},
+		{
+			name:     "Indented code inside a loose item",
+			input:    "- a\n\n      code",
+			expected: "<ul>\n<li>\n<p>a</p>\n<pre><code>code\n</code></pre>\n</li>\n</ul>\n",
+		},
+		{
+			name:     "Task list",
+			input:    "- [ ] todo\n- [x] done\n- [y] not a task",
+			expected: "<ul>\n<li><input disabled=\"\" type=\"checkbox\"> todo</li>\n<li><input checked=\"\" disabled=\"\" type=\"checkbox\"> done</li>\n<li>[y] not a task</li>\n</ul>\n",
+		},
+		{
+			name:     "Emphasis is not a bullet",
+			input:    "*not* a list\n\n2024-01-01 is a date",
+			expected: "<p><em>not</em> a list</p>\n<p>2024-01-01 is a date</p>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := render(tt.input); got != tt.expected {
+				t.Errorf("render() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseQuotes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Quote as the first block",
+			input:    "> hello",
+			expected: "<blockquote>\n<p>hello</p>\n</blockquote>\n",
+		},
+		{
+			name:     "Quoted lines form one paragraph",
+			input:    "> a\n> b\n\ntext",
+			expected: "<blockquote>\n<p>a b</p>\n</blockquote>\n<p>text</p>\n",
+		},
+		{
+			name:     "Quote after a paragraph",
+			input:    "text\n\n> q",
+			expected: "<p>text</p>\n<blockquote>\n<p>q</p>\n</blockquote>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := render(tt.input); got != tt.expected {
+				t.Errorf("render() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseGFMExtensions(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Table with alignment",
+			input:    "| a | b | c |\n|:--|:-:|--:|\n| 1 | `x` | 3 |",
+			expected: "<table>\n<thead>\n<tr>\n<th style=\"text-align:left\">a</th>\n<th style=\"text-align:center\">b</th>\n<th style=\"text-align:right\">c</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td style=\"text-align:left\">1</td>\n<td style=\"text-align:center\"><code>x</code></td>\n<td style=\"text-align:right\">3</td>\n</tr>\n</tbody>\n</table>\n",
+		},
+		{
+			name:     "Table without outer pipes pads and cuts rows",
+			input:    "a | b\n--|--\n1 | 2 | 3\n4\n\nx",
+			expected: "<table>\n<thead>\n<tr>\n<th>a</th>\n<th>b</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>1</td>\n<td>2</td>\n</tr>\n<tr>\n<td>4</td>\n<td></td>\n</tr>\n</tbody>\n</table>\n<p>x</p>\n",
+		},
+		{
+			name:     "Header only table",
+			input:    "| a | b |\n|--|--:|",
+			expected: "<table>\n<thead>\n<tr>\n<th>a</th>\n<th style=\"text-align:right\">b</th>\n</tr>\n</thead>\n</table>\n",
+		},
+		{
+			name:     "Escaped pipes and inline markup in cells",
+			input:    "| `a\\|b` | c |\n|---|---|\n| *x* | - |",
+			expected: "<table>\n<thead>\n<tr>\n<th><code>a|b</code></th>\n<th>c</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td><em>x</em></td>\n<td>-</td>\n</tr>\n</tbody>\n</table>\n",
+		},
+		{
+			name:     "Pipes without a delimiter row are text",
+			input:    "want: Any | None",
+			expected: "<p>want: Any | None</p>\n",
+		},
+		{
+			name:     "Strikethrough",
+			input:    "~a~ ~~b~~ ~~~c~~~",
+			expected: "<p><del>a</del> <del>b</del> ~~~c~~~</p>\n",
+		},
+		{
+			name:     "Horizontal rules",
+			input:    "- - -\n* * *\n___",
+			expected: "<hr>\n<hr>\n<hr>\n",
+		},
+		{
+			name:     "Rule between paragraphs",
+			input:    "text\n***\nmore",
+			expected: "<p>text</p>\n<hr>\n<p>more</p>\n",
+		},
+		{
+			name:     "Rule ends a list",
+			input:    "- a\n\n---",
+			expected: "<ul>\n<li>a</li>\n</ul>\n<hr>\n",
+		},
+		{
+			name:     "Setext heading level 2",
+			input:    "Title\n---",
+			expected: "<h2>Title</h2>\n",
+		},
+		{
+			name:     "Setext heading level 1",
+			input:    "Title\n===",
+			expected: "<h1>Title</h1>\n",
+		},
+		{
+			name:     "Setext heading of several lines",
+			input:    "A *long*\ntitle\n--\ntext",
+			expected: "<h2>A <em>long</em> title</h2>\n<p>text</p>\n",
+		},
+		{
+			name:     "Underline after a heading or list",
+			input:    "# a\n===\n- b\n---",
+			expected: "<h1>a</h1>\n<p>===</p>\n<ul>\n<li>b</li>\n</ul>\n<hr>\n",
+		},
+		{
+			name:     "Setext heading in a list item",
+			input:    "- Foo\n  ---\n- Bar\n\n  Baz\n  ===",
+			expected: "<ul>\n<li>\n<h2>Foo</h2>\n</li>\n<li>\n<p>Bar</p>\n<h1>Baz</h1>\n</li>\n</ul>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := render(tt.input); got != tt.expected {
+				t.Errorf("render() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseMath(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Inline dollars",
+			input:    "Let $y = y(x)$ be a function",
+			expected: "<p>Let <span class=\"math inline\">\\(y = y(x)\\)</span> be a function</p>\n",
+		},
+		{
+			name:     "Display dollars on their own lines",
+			input:    "$$\ny(x+h) = y(x) + hy'(x) + \\cdots\n$$",
+			expected: "<p><span class=\"math display\">\\[\ny(x+h) = y(x) + hy'(x) + \\cdots\n\\]</span></p>\n",
+		},
+		{
+			name:     "Inline display dollars",
+			input:    "so $$a_1 * b_2$$ holds",
+			expected: "<p>so <span class=\"math display\">\\[a_1 * b_2\\]</span> holds</p>\n",
+		},
+		{
+			name:     "Parenthesis and bracket delimiters",
+			input:    "\\(a < b\\) and \\[c > d\\]",
+			expected: "<p><span class=\"math inline\">\\(a &lt; b\\)</span> and <span class=\"math display\">\\[c &gt; d\\]</span></p>\n",
+		},
+		{
+			name:     "Environment",
+			input:    "\\begin{align}\na &= b \\\\\nc &= d\n\\end{align}",
+			expected: "<p><span class=\"math display\">\\begin{align}\na &amp;= b \\\\\nc &amp;= d\n\\end{align}</span></p>\n",
+		},
+		{
+			name:     "Escaped dollars are text",
+			input:    "costs \\$5, not \\$6",
+			expected: "<p>costs \\$5, not \\$6</p>\n",
+		},
+		{
+			name:     "Prices are not math",
+			input:    "costs $5 and $10 today",
+			expected: "<p>costs $5 and $10 today</p>\n",
+		},
+		{
+			name:     "Escaped dollar inside math",
+			input:    "$\\$x$",
+			expected: "<p><span class=\"math inline\">\\(\\$x\\)</span></p>\n",
+		},
+		{
+			name:     "Markdown is not processed inside math",
+			input:    "$a*b*c$ and *d*",
+			expected: "<p><span class=\"math inline\">\\(a*b*c\\)</span> and <em>d</em></p>\n",
+		},
+		{
+			name:     "Unclosed display math",
+			input:    "$$x\n\ny",
+			expected: "<p>$$x</p>\n<p>y</p>\n",
+		},
+		{
+			name:     "Unclosed bracket is an escape",
+			input:    "\\[not math",
+			expected: "<p>[not math</p>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := render(tt.input); got != tt.expected {
+				t.Errorf("render() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
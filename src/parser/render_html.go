@@ -0,0 +1,219 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iashyam/gossg/src/parser/ast"
+)
+
+// HTMLRenderer writes a document tree as HTML.
+type HTMLRenderer struct {
+	policy    HTMLPolicy
+	allowlist Allowlist
+}
+
+// HTMLOption configures an HTMLRenderer.
+type HTMLOption func(*HTMLRenderer)
+
+// WithHTMLPolicy sets how raw HTML in the input is written out.
+func WithHTMLPolicy(policy HTMLPolicy) HTMLOption {
+	return func(r *HTMLRenderer) { r.policy = policy }
+}
+
+// WithAllowlist replaces DefaultAllowlist for the HTMLSanitize policy.
+func WithAllowlist(allow Allowlist) HTMLOption {
+	return func(r *HTMLRenderer) { r.allowlist = allow }
+}
+
+func NewHTMLRenderer(opts ...HTMLOption) *HTMLRenderer {
+	r := &HTMLRenderer{allowlist: DefaultAllowlist}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Render returns the HTML for n and everything below it.
+func (r *HTMLRenderer) Render(n ast.Node) string {
+	var sb strings.Builder
+	r.render(&sb, n)
+	return sb.String()
+}
+
+func (r *HTMLRenderer) renderChildren(sb *strings.Builder, n ast.Node) {
+	for _, child := range n.Children() {
+		r.render(sb, child)
+	}
+}
+
+func (r *HTMLRenderer) render(sb *strings.Builder, n ast.Node) {
+	switch n := n.(type) {
+	case *ast.Heading:
+		sb.WriteString(fmt.Sprintf("<h%d>", n.Level))
+		r.renderChildren(sb, n)
+		sb.WriteString(fmt.Sprintf("</h%d>\n", n.Level))
+	case *ast.Paragraph:
+		sb.WriteString("<p>")
+		r.renderChildren(sb, n)
+		sb.WriteString("</p>\n")
+	case *ast.Blockquote:
+		sb.WriteString("<blockquote>\n")
+		r.renderChildren(sb, n)
+		sb.WriteString("</blockquote>\n")
+	case *ast.List:
+		r.renderList(sb, n)
+	case *ast.CodeBlock:
+		// The language becomes a language-* class, which highlight.js picks up.
+		sb.WriteString("<pre><code")
+		if lang := n.Language(); lang != "" {
+			sb.WriteString(fmt.Sprintf(` class="language-%s"`, escapeHTML(lang)))
+		}
+		sb.WriteString(">")
+		sb.WriteString(escapeHTML(n.Literal))
+		sb.WriteString("</code></pre>\n")
+	case *ast.HTMLBlock:
+		r.writeHTML(sb, n.Literal)
+		sb.WriteString("\n")
+	case *ast.Table:
+		r.renderTable(sb, n)
+	case *ast.TableRow:
+		sb.WriteString("<tr>\n")
+		r.renderChildren(sb, n)
+		sb.WriteString("</tr>\n")
+	case *ast.TableCell:
+		tag := "td"
+		if n.Header {
+			tag = "th"
+		}
+		sb.WriteString("<" + tag)
+		if n.Alignment != ast.AlignNone {
+			sb.WriteString(` style="text-align:` + string(n.Alignment) + `"`)
+		}
+		sb.WriteString(">")
+		r.renderChildren(sb, n)
+		sb.WriteString("</" + tag + ">\n")
+	case *ast.ThematicBreak:
+		sb.WriteString("<hr>\n")
+	case *ast.Text:
+		sb.WriteString(escapeText(n.Literal))
+	case *ast.SoftBreak:
+		sb.WriteString(" ")
+	case *ast.Emphasis:
+		tag := "em"
+		if n.Level == 2 {
+			tag = "strong"
+		}
+		sb.WriteString("<" + tag + ">")
+		r.renderChildren(sb, n)
+		sb.WriteString("</" + tag + ">")
+	case *ast.Strikethrough:
+		sb.WriteString("<del>")
+		r.renderChildren(sb, n)
+		sb.WriteString("</del>")
+	case *ast.Code:
+		sb.WriteString("<code>" + escapeHTML(n.Literal) + "</code>")
+	case *ast.RawHTML:
+		r.writeHTML(sb, n.Literal)
+	case *ast.Math:
+		// MathJax typesets these spans as configured in base.html, using the
+		// same markup as goldmark-mathjax.
+		switch {
+		case n.Environment != "":
+			sb.WriteString(`<span class="math display">` + escapeHTML(n.Literal) + `</span>`)
+		case n.Display:
+			sb.WriteString(`<span class="math display">\[` + escapeHTML(n.Literal) + `\]</span>`)
+		default:
+			sb.WriteString(`<span class="math inline">\(` + escapeHTML(n.Literal) + `\)</span>`)
+		}
+	default:
+		r.renderChildren(sb, n)
+	}
+}
+
+func (r *HTMLRenderer) renderList(sb *strings.Builder, list *ast.List) {
+	tag := "ul"
+	if list.Ordered {
+		tag = "ol"
+	}
+	if list.Ordered && list.Start != 1 {
+		sb.WriteString(fmt.Sprintf("<ol start=\"%d\">\n", list.Start))
+	} else {
+		sb.WriteString("<" + tag + ">\n")
+	}
+	for _, item := range list.Children() {
+		r.renderListItem(sb, item.(*ast.ListItem), list.Tight)
+	}
+	sb.WriteString("</" + tag + ">\n")
+}
+
+// renderListItem writes an item. Tight lists write paragraph text directly
+// in the <li>, loose lists wrap every paragraph in <p>.
+func (r *HTMLRenderer) renderListItem(sb *strings.Builder, item *ast.ListItem, tight bool) {
+	sb.WriteString("<li>")
+	if !tight {
+		sb.WriteString("\n")
+	}
+	for i, child := range item.Children() {
+		para, ok := child.(*ast.Paragraph)
+		if !ok {
+			if !strings.HasSuffix(sb.String(), "\n") {
+				sb.WriteString("\n")
+			}
+			r.render(sb, child)
+			continue
+		}
+		if !tight {
+			sb.WriteString("<p>")
+		}
+		if i == 0 {
+			sb.WriteString(taskBox(item.Task))
+		}
+		r.renderChildren(sb, para)
+		if !tight {
+			sb.WriteString("</p>\n")
+		}
+	}
+	sb.WriteString("</li>\n")
+}
+
+// taskBox renders the checkbox of a GFM task list item.
+func taskBox(state ast.TaskState) string {
+	switch state {
+	case ast.TaskChecked:
+		return `<input checked="" disabled="" type="checkbox">`
+	case ast.TaskUnchecked:
+		return `<input disabled="" type="checkbox">`
+	}
+	return ""
+}
+
+// renderTable writes the first row as the table head and the rest, if any,
+// as its body.
+func (r *HTMLRenderer) renderTable(sb *strings.Builder, table *ast.Table) {
+	rows := table.Children()
+	sb.WriteString("<table>\n<thead>\n")
+	r.render(sb, rows[0])
+	sb.WriteString("</thead>\n")
+	if len(rows) > 1 {
+		sb.WriteString("<tbody>\n")
+		for _, row := range rows[1:] {
+			r.render(sb, row)
+		}
+		sb.WriteString("</tbody>\n")
+	}
+	sb.WriteString("</table>\n")
+}
+
+// writeHTML writes raw HTML from the input according to the renderer's
+// policy.
+func (r *HTMLRenderer) writeHTML(sb *strings.Builder, raw string) {
+	switch r.policy {
+	case HTMLEscape:
+		sb.WriteString(escapeHTML(raw))
+	case HTMLSanitize:
+		sb.WriteString(sanitizeHTML(raw, r.allowlist))
+	default:
+		sb.WriteString(raw)
+	}
+}
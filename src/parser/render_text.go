@@ -0,0 +1,48 @@
+package parser
+
+import (
+	"html"
+	"strings"
+
+	"github.com/iashyam/gossg/src/parser/ast"
+)
+
+// RenderText returns the text a reader sees in n: markup and raw HTML are
+// dropped, code and math are kept verbatim and blocks end with a newline.
+func RenderText(n ast.Node) string {
+	var sb strings.Builder
+	ast.Walk(n, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch n := n.(type) {
+		case *ast.Text:
+			if entering {
+				sb.WriteString(html.UnescapeString(n.Literal))
+			}
+		case *ast.Code:
+			if entering {
+				sb.WriteString(n.Literal)
+			}
+		case *ast.Math:
+			if entering {
+				sb.WriteString(n.Literal)
+			}
+		case *ast.CodeBlock:
+			if entering {
+				sb.WriteString(n.Literal)
+			}
+		case *ast.SoftBreak:
+			if entering {
+				sb.WriteString(" ")
+			}
+		case *ast.TableCell:
+			if !entering {
+				sb.WriteString("\t")
+			}
+		case *ast.Heading, *ast.Paragraph, *ast.TableRow:
+			if !entering {
+				sb.WriteString("\n")
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return sb.String()
+}
@@ -0,0 +1,143 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/iashyam/gossg/src/parser/ast"
+)
+
+var delimiterRowRe = regexp.MustCompile(`^\|?\s*:?-+:?\s*(?:\|\s*:?-+:?\s*)*\|?\s*$`)
+
+// isTableStart reports whether line is a table header row followed by a
+// delimiter row with the same number of cells.
+func isTableStart(line, next string) bool {
+	if !strings.Contains(line, "|") || !delimiterRowRe.MatchString(next) {
+		return false
+	}
+	header, _ := splitTableRow(line)
+	delimiter, _ := splitTableRow(next)
+	return len(header) == len(delimiter)
+}
+
+// endsTable reports whether line starts a block that interrupts a table.
+func endsTable(line string) bool {
+	width, size := lineIndent(line)
+	if width >= 4 {
+		return false
+	}
+	line = line[size:]
+	_, _, fence := openingFence(line)
+	return fence || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ">")
+}
+
+// splitTableRow splits a row into its trimmed cells and the offset of each
+// cell within row. Outer pipes are optional and \| is a literal pipe inside a
+// cell.
+func splitTableRow(row string) ([]string, []int) {
+	start, end := 0, len(row)
+	for start < end && (row[start] == ' ' || row[start] == '\t') {
+		start++
+	}
+	for end > start && (row[end-1] == ' ' || row[end-1] == '\t') {
+		end--
+	}
+	if start < end && row[start] == '|' {
+		start++
+	}
+	if end > start && row[end-1] == '|' && (end-2 < start || row[end-2] != '\\') {
+		end--
+	}
+
+	var cells []string
+	var offsets []int
+	var cell strings.Builder
+	cellStart := start
+	flush := func(at int) {
+		text := cell.String()
+		offsets = append(offsets, cellStart+len(text)-len(strings.TrimLeft(text, " \t")))
+		cells = append(cells, strings.TrimSpace(text))
+		cell.Reset()
+		cellStart = at
+	}
+	for i := start; i < end; i++ {
+		switch {
+		case row[i] == '\\' && i+1 < end && row[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case row[i] == '|':
+			flush(i + 1)
+		default:
+			cell.WriteByte(row[i])
+		}
+	}
+	flush(end)
+	return cells, offsets
+}
+
+// tableAlignments reads the alignment of each column from the delimiter row.
+func tableAlignments(row string) []ast.Alignment {
+	cells, _ := splitTableRow(row)
+	aligns := make([]ast.Alignment, len(cells))
+	for i, cell := range cells {
+		left, right := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			aligns[i] = ast.AlignCenter
+		case right:
+			aligns[i] = ast.AlignRight
+		case left:
+			aligns[i] = ast.AlignLeft
+		}
+	}
+	return aligns
+}
+
+// parseTable adds a pipe table. Body rows are padded or cut to the number of
+// header cells.
+func (p *Parser) parseTable(parent ast.Node) {
+	token := p.tokens[p.pos]
+	p.pos++
+	table := &ast.Table{Alignments: tableAlignments(lineAt(token.value, strings.IndexByte(token.value, '\n')+1))}
+	appendNode(parent, table, token)
+
+	pos := token.pos
+	for i, row := range strings.Split(token.value, "\n") {
+		if i != 1 { // skip the delimiter row
+			p.parseTableRow(table, row, pos, i == 0)
+		}
+		pos.Offset += len(row) + 1
+		pos.Line++
+	}
+}
+
+// parseTableRow adds the row starting at pos to table.
+func (p *Parser) parseTableRow(table *ast.Table, row string, pos ast.Position, header bool) {
+	tr := &ast.TableRow{Header: header}
+	tr.SetPos(pos)
+	table.AppendChild(tr)
+
+	cells, offsets := splitTableRow(row)
+	for i, align := range table.Alignments {
+		cell := &ast.TableCell{Header: header, Alignment: align}
+		cell.SetPos(pos)
+		tr.AppendChild(cell)
+		if i >= len(cells) {
+			continue
+		}
+		cellPos := ast.Position{Offset: pos.Offset + offsets[i], Line: pos.Line, Column: offsets[i] + 1}
+		cell.SetPos(cellPos)
+		p.parseCell(cell, cells[i], cellPos)
+	}
+}
+
+// parseCell parses the inline content of a table cell found at pos.
+func (p *Parser) parseCell(cell *ast.TableCell, text string, pos ast.Position) {
+	tokens := tokenizeInline(text)
+	for i := range tokens {
+		tokens[i].pos.Offset += pos.Offset
+		tokens[i].pos.Line = pos.Line
+		tokens[i].pos.Column += pos.Column - 1
+	}
+	NewParser(tokens).parseInline(cell)
+}
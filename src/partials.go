@@ -0,0 +1,132 @@
+package src
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Partials holds the reusable template fragments of the theme, loaded from
+// the embedded partials directory and optionally overridden by files in the
+// site's layouts/partials directory.
+type Partials struct {
+	// tmpl is only cloned, never executed, so page templates can be built
+	// from it at any time. render is the copy partial executes.
+	tmpl   *template.Template
+	render *template.Template
+	// hash identifies the templates, see HashFS.
+	hash string
+
+	mu     sync.Mutex
+	cached map[string]template.HTML
+}
+
+// LoadPartials parses the partials in dir of fsys, then those in userDir,
+// which replace embedded partials of the same name. userDir may be missing.
+// funcs are made available to the partials along with partial and
+// partialCached.
+func LoadPartials(fsys fs.FS, dir, userDir string, funcs template.FuncMap) (*Partials, error) {
+	p := &Partials{
+		cached: make(map[string]template.HTML),
+		hash:   HashFS(fsys, dir) + HashFS(os.DirFS(userDir), "."),
+	}
+	p.tmpl = template.New("partials").Funcs(funcs).Funcs(p.Funcs())
+
+	if err := parseTemplateDir(p.tmpl, fsys, dir); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(userDir); err == nil {
+		if err := parseTemplateDir(p.tmpl, os.DirFS(userDir), "."); err != nil {
+			return nil, err
+		}
+	}
+
+	render, err := p.tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	p.render = render
+	return p, nil
+}
+
+// parseTemplateDir adds the .html files under dir of fsys to t, named by
+// their path relative to dir. Files replace templates of the same name.
+func parseTemplateDir(t *template.Template, fsys fs.FS, dir string) error {
+	return fs.WalkDir(fsys, dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if file == dir && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || path.Ext(file) != ".html" {
+			return nil
+		}
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(file, dir), "/")
+		if _, err := t.New(name).Parse(string(content)); err != nil {
+			return fmt.Errorf("failed to parse template %s: %w", file, err)
+		}
+		return nil
+	})
+}
+
+// Funcs returns the partial and partialCached template functions.
+func (p *Partials) Funcs() template.FuncMap {
+	return template.FuncMap{
+		"partial":       p.Render,
+		"partialCached": p.RenderCached,
+	}
+}
+
+// Clone returns a template set holding every partial, to which page
+// templates can be added so they can also use {{ template "name.html" }}.
+func (p *Partials) Clone() (*template.Template, error) {
+	return p.tmpl.Clone()
+}
+
+// Render executes the partial name, with or without its .html extension,
+// with ctx as its data.
+func (p *Partials) Render(name string, ctx any) (template.HTML, error) {
+	if !strings.HasSuffix(name, ".html") {
+		name += ".html"
+	}
+	t := p.render.Lookup(name)
+	if t == nil {
+		return "", fmt.Errorf("partial %q not found", name)
+	}
+	var buf strings.Builder
+	if err := t.Execute(&buf, ctx); err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
+
+// RenderCached renders the partial name once per build and reuses the
+// result. Variants give separate cache entries, for partials that depend on
+// part of their context: partialCached "menu" . .Page.Section
+func (p *Partials) RenderCached(name string, ctx any, variants ...any) (template.HTML, error) {
+	key := fmt.Sprintf("%s%v", name, variants)
+	p.mu.Lock()
+	out, ok := p.cached[key]
+	p.mu.Unlock()
+	if ok {
+		return out, nil
+	}
+
+	out, err := p.Render(name, ctx)
+	if err != nil {
+		return "", err
+	}
+	p.mu.Lock()
+	p.cached[key] = out
+	p.mu.Unlock()
+	return out, nil
+}
@@ -0,0 +1,68 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestPartials(t *testing.T) {
+	theme := fstest.MapFS{
+		"partials/greeting.html": {Data: []byte(`Hello, {{ . }}!`)},
+		"partials/footer.html":   {Data: []byte(`theme footer`)},
+		"partials/nested.html":   {Data: []byte(`[{{ partial "greeting" . }}]`)},
+		"partials/counter.html":  {Data: []byte(`{{ .N }}`)},
+	}
+	userDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(userDir, "footer.html"), []byte(`site footer`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	partials, err := LoadPartials(theme, "partials", userDir, FuncMap(Config{}))
+	if err != nil {
+		t.Fatalf("LoadPartials() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		tmpl     string
+		data     any
+		expected string
+		wantErr  bool
+	}{
+		{"Partial", `{{ partial "greeting" . }}`, "<Ada>", "Hello, &lt;Ada&gt;!", false},
+		{"With extension", `{{ partial "greeting.html" "Alan" }}`, nil, "Hello, Alan!", false},
+		{"User override", `{{ partial "footer" . }}`, nil, "site footer", false},
+		{"Nested partial", `{{ partial "nested" "Ada" }}`, nil, "[Hello, Ada!]", false},
+		{"Template action", `{{ template "greeting.html" "Ada" }}`, nil, "Hello, Ada!", false},
+		{"Missing partial", `{{ partial "missing" . }}`, nil, "", true},
+		{
+			name:     "Cached partial",
+			tmpl:     `{{ partialCached "counter" (dict "N" 1) }}{{ partialCached "counter" (dict "N" 2) }}{{ partialCached "counter" (dict "N" 3) "other" }}`,
+			expected: "113",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := partials.Clone()
+			if err != nil {
+				t.Fatalf("Clone() error = %v", err)
+			}
+			tmpl, err := set.New("page").Parse(tt.tmpl)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			var out strings.Builder
+			err = tmpl.Execute(&out, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && out.String() != tt.expected {
+				t.Errorf("Execute() = %q, want %q", out.String(), tt.expected)
+			}
+		})
+	}
+}
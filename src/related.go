@@ -0,0 +1,138 @@
+package src
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// stopWords are left out of content similarity.
+var stopWords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`about after also an and are as at be because been but by can could
+		did do does for from had has have how if in into is it its just more most not now of on one or
+		other our out over so some such than that the their them then there these they this those to
+		under up was we were what when where which while who why will with would you your`) {
+		stopWords[w] = true
+	}
+}
+
+// computeRelated fills in the Related posts of every post, scoring each
+// pair by the cosine similarity of their IDF-weighted tags and, when the
+// content weight is set, of their TF-IDF weighted words.
+func (s *Site) computeRelated() {
+	cfg := s.Config.Related.withDefaults()
+	if cfg.Count <= 0 || len(s.Posts) < 2 {
+		return
+	}
+
+	tagVecs := make([]map[string]float64, len(s.Posts))
+	for i, post := range s.Posts {
+		vec := map[string]float64{}
+		for _, tag := range post.Tags {
+			vec[strings.ToLower(tag)] = 1
+		}
+		tagVecs[i] = vec
+	}
+	weighIDF(tagVecs)
+
+	var wordVecs []map[string]float64
+	if cfg.ContentWeight > 0 {
+		wordVecs = make([]map[string]float64, len(s.Posts))
+		for i, post := range s.Posts {
+			wordVecs[i] = termFrequencies(plainify(post.ContentHTML))
+		}
+		weighIDF(wordVecs)
+	}
+
+	type candidate struct {
+		post  int
+		score float64
+	}
+	for i := range s.Posts {
+		var candidates []candidate
+		for j := range s.Posts {
+			if i == j {
+				continue
+			}
+			score := cfg.TagWeight * cosine(tagVecs[i], tagVecs[j])
+			if wordVecs != nil {
+				score += cfg.ContentWeight * cosine(wordVecs[i], wordVecs[j])
+			}
+			if score > 0 {
+				candidates = append(candidates, candidate{j, score})
+			}
+		}
+		// Posts are sorted newest first, so ties go to the newer post
+		sort.SliceStable(candidates, func(a, b int) bool {
+			return candidates[a].score > candidates[b].score
+		})
+
+		related := make([]*Post, 0, min(cfg.Count, len(candidates)))
+		for _, c := range candidates[:min(cfg.Count, len(candidates))] {
+			related = append(related, &s.Posts[c.post])
+		}
+		s.Posts[i].Related = related
+	}
+}
+
+// termFrequencies counts the words of text, ignoring case, short words and
+// stop words.
+func termFrequencies(text string) map[string]float64 {
+	tf := map[string]float64{}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, w := range words {
+		if len([]rune(w)) < 3 || stopWords[w] {
+			continue
+		}
+		tf[w]++
+	}
+	return tf
+}
+
+// weighIDF multiplies every term of vecs by its inverse document frequency,
+// so terms shared by many documents count for less.
+func weighIDF(vecs []map[string]float64) {
+	df := map[string]int{}
+	for _, vec := range vecs {
+		for term := range vec {
+			df[term]++
+		}
+	}
+	n := float64(len(vecs))
+	for _, vec := range vecs {
+		for term, f := range vec {
+			vec[term] = f * (1 + math.Log(n/float64(df[term])))
+		}
+	}
+}
+
+// cosine returns the cosine similarity of two sparse vectors. Terms are
+// summed in order so that the same vectors always give the same score.
+func cosine(a, b map[string]float64) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	var dot float64
+	for _, term := range slices.Sorted(maps.Keys(a)) {
+		dot += a[term] * b[term]
+	}
+	if dot == 0 {
+		return 0
+	}
+	return dot / (norm(a) * norm(b))
+}
+
+func norm(v map[string]float64) float64 {
+	var sum float64
+	for _, term := range slices.Sorted(maps.Keys(v)) {
+		sum += v[term] * v[term]
+	}
+	return math.Sqrt(sum)
+}
@@ -0,0 +1,97 @@
+package src
+
+import (
+	"html/template"
+	"reflect"
+	"testing"
+
+	"github.com/iashyam/gossg/src/parser"
+)
+
+func TestComputeRelated(t *testing.T) {
+	post := func(title, content string, tags ...string) Post {
+		return Post{
+			Frontmatter: parser.Frontmatter{Title: title, Tags: tags},
+			ContentHTML: template.HTML(content),
+		}
+	}
+	zero, half := 0.0, 0.5
+
+	tests := []struct {
+		name     string
+		posts    []Post
+		cfg      RelatedConfig
+		expected map[string][]string
+	}{
+		{
+			name: "Rare shared tags count for more",
+			posts: []Post{
+				post("A", "", "go", "testing"),
+				post("B", "", "go", "testing"),
+				post("C", "", "go"),
+				post("D", "", "go", "physics"),
+				post("E", "", "Physics"),
+			},
+			cfg: RelatedConfig{ContentWeight: &zero},
+			expected: map[string][]string{
+				"A": {"B", "C", "D"},
+				"C": {"A", "B", "D"},
+				"E": {"D"},
+			},
+		},
+		{
+			name: "Content similarity",
+			posts: []Post{
+				post("Waves", "<p>The wave equation and finite difference schemes</p>"),
+				post("Cooking", "<p>Bread needs flour, water and patience</p>"),
+				post("Schemes", "<p>Stability of finite difference schemes</p>"),
+			},
+			cfg: RelatedConfig{ContentWeight: &half},
+			expected: map[string][]string{
+				"Waves":   {"Schemes"},
+				"Cooking": nil,
+			},
+		},
+		{
+			name: "Count",
+			posts: []Post{
+				post("A", "", "go"),
+				post("B", "", "go"),
+				post("C", "", "go"),
+			},
+			cfg:      RelatedConfig{Count: 1, ContentWeight: &zero},
+			expected: map[string][]string{"A": {"B"}},
+		},
+		{
+			name: "Turned off",
+			posts: []Post{
+				post("A", "", "go"),
+				post("B", "", "go"),
+			},
+			cfg:      RelatedConfig{Count: -1},
+			expected: map[string][]string{"A": nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := NewSite(Config{Related: tt.cfg})
+			site.Posts = tt.posts
+			site.computeRelated()
+
+			for _, p := range site.Posts {
+				expected, ok := tt.expected[p.Title]
+				if !ok {
+					continue
+				}
+				var titles []string
+				for _, r := range p.Related {
+					titles = append(titles, r.Title)
+				}
+				if !reflect.DeepEqual(titles, expected) {
+					t.Errorf("Related(%s) = %v, want %v", p.Title, titles, expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package src
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Timings adds up how long named pieces of work took, like rendering a file
+// or executing a template. It is safe for concurrent use.
+type Timings struct {
+	mu    sync.Mutex
+	total map[string]time.Duration
+	count map[string]int
+}
+
+// NewTimings returns an empty Timings.
+func NewTimings() *Timings {
+	return &Timings{total: map[string]time.Duration{}, count: map[string]int{}}
+}
+
+// Add records that name took d once more.
+func (t *Timings) Add(name string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.total[name] += d
+	t.count[name]++
+}
+
+// Slowest returns the n names that took longest in total, slowest first.
+func (t *Timings) Slowest(n int) []Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timings := make([]Timing, 0, len(t.total))
+	for name, d := range t.total {
+		timings = append(timings, newTiming(name, d, t.count[name]))
+	}
+	slices.SortFunc(timings, func(a, b Timing) int {
+		if c := cmp.Compare(b.Milliseconds, a.Milliseconds); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return timings[:min(n, len(timings))]
+}
+
+// Timing is how long something took in a build.
+type Timing struct {
+	Name         string  `json:"name"`
+	Count        int     `json:"count,omitempty"`
+	Milliseconds float64 `json:"ms"`
+}
+
+func newTiming(name string, d time.Duration, count int) Timing {
+	return Timing{Name: name, Count: count, Milliseconds: float64(d.Microseconds()) / 1000}
+}
+
+// Report summarizes a build: what it produced, how well the cache did and
+// where the time went.
+type Report struct {
+	start time.Time
+
+	// Sections counts the pages of each kind of content.
+	Sections map[string]int `json:"sections"`
+	Cache    struct {
+		Hits    int     `json:"hits"`
+		Misses  int     `json:"misses"`
+		HitRate float64 `json:"hitRate"`
+	} `json:"cache"`
+	Outputs struct {
+		Written      int   `json:"written"`
+		Unchanged    int   `json:"unchanged"`
+		Removed      int   `json:"removed"`
+		BytesWritten int64 `json:"bytesWritten"`
+	} `json:"outputs"`
+	// Phases are the stages of the build in order.
+	Phases []Timing `json:"phases"`
+	// SlowestFiles are the Markdown files that took longest to render and
+	// SlowestTemplates the templates that took longest over all pages.
+	SlowestFiles      []Timing `json:"slowestFiles"`
+	SlowestTemplates  []Timing `json:"slowestTemplates"`
+	TotalMilliseconds float64  `json:"totalMs"`
+}
+
+// slowestCount is the number of files and templates a Report lists.
+const slowestCount = 5
+
+// NewReport starts timing a build.
+func NewReport() *Report {
+	return &Report{start: time.Now()}
+}
+
+// Phase records that the phase name, which started at start, is done.
+func (r *Report) Phase(name string, start time.Time) {
+	r.Phases = append(r.Phases, newTiming(name, time.Since(start), 0))
+}
+
+// Finish completes the report with what site and builder did. templates
+// holds the time spent executing each template.
+func (r *Report) Finish(site *Site, builder *Builder, templates *Timings) {
+	r.Sections = map[string]int{
+		"posts":    len(site.Posts),
+		"pages":    len(site.Pages),
+		"projects": len(site.Projects),
+		"tags":     len(site.Tags),
+		"authors":  len(site.Authors),
+	}
+
+	r.Cache.Hits, r.Cache.Misses = site.Cache.Hits, site.Cache.Misses
+	if lookups := r.Cache.Hits + r.Cache.Misses; lookups > 0 {
+		r.Cache.HitRate = float64(r.Cache.Hits) / float64(lookups)
+	}
+
+	r.Outputs.Written = builder.Written
+	r.Outputs.Unchanged = builder.Unchanged
+	r.Outputs.Removed = builder.Removed
+	r.Outputs.BytesWritten = builder.BytesWritten
+
+	r.SlowestFiles = site.RenderTimes.Slowest(slowestCount)
+	r.SlowestTemplates = templates.Slowest(slowestCount)
+	r.TotalMilliseconds = newTiming("", time.Since(r.start), 0).Milliseconds
+}
+
+// WriteJSON writes the report as JSON, for CI dashboards.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteTable writes the report as text, aligning each part separately.
+func (r *Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "Pages")
+	for _, section := range slices.Sorted(maps.Keys(r.Sections)) {
+		fmt.Fprintf(tw, "  %s\t%d\n", section, r.Sections[section])
+	}
+
+	fmt.Fprintln(tw, "Cache")
+	fmt.Fprintf(tw, "  hits\t%d (%.0f%%)\n", r.Cache.Hits, r.Cache.HitRate*100)
+	fmt.Fprintf(tw, "  misses\t%d\n", r.Cache.Misses)
+
+	fmt.Fprintln(tw, "Output")
+	fmt.Fprintf(tw, "  written\t%d (%s)\n", r.Outputs.Written, formatBytes(r.Outputs.BytesWritten))
+	fmt.Fprintf(tw, "  unchanged\t%d\n", r.Outputs.Unchanged)
+	fmt.Fprintf(tw, "  removed\t%d\n", r.Outputs.Removed)
+
+	timings := func(title string, list []Timing) {
+		if len(list) == 0 {
+			return
+		}
+		fmt.Fprintln(tw, title)
+		for _, t := range list {
+			if t.Count > 1 {
+				fmt.Fprintf(tw, "  %s\t%.1fms (%d×)\n", t.Name, t.Milliseconds, t.Count)
+			} else {
+				fmt.Fprintf(tw, "  %s\t%.1fms\n", t.Name, t.Milliseconds)
+			}
+		}
+	}
+	timings("Slowest files", r.SlowestFiles)
+	timings("Slowest templates", r.SlowestTemplates)
+	timings("Phases", r.Phases)
+	fmt.Fprintf(tw, "Total %.1fms\n", r.TotalMilliseconds)
+	return tw.Flush()
+}
+
+// formatBytes returns n in B, KB or MB.
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
@@ -0,0 +1,91 @@
+package src
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimingsSlowest(t *testing.T) {
+	timings := NewTimings()
+	timings.Add("post.html", 3*time.Millisecond)
+	timings.Add("list.html", 2*time.Millisecond)
+	timings.Add("post.html", 2*time.Millisecond)
+	timings.Add("tags.html", 2*time.Millisecond)
+	timings.Add("index.html", time.Millisecond)
+
+	tests := []struct {
+		name string
+		n    int
+		want []Timing
+	}{
+		{
+			name: "Slowest first, ties by name",
+			n:    3,
+			want: []Timing{
+				{Name: "post.html", Count: 2, Milliseconds: 5},
+				{Name: "list.html", Count: 1, Milliseconds: 2},
+				{Name: "tags.html", Count: 1, Milliseconds: 2},
+			},
+		},
+		{
+			name: "Fewer than asked for",
+			n:    10,
+			want: []Timing{
+				{Name: "post.html", Count: 2, Milliseconds: 5},
+				{Name: "list.html", Count: 1, Milliseconds: 2},
+				{Name: "tags.html", Count: 1, Milliseconds: 2},
+				{Name: "index.html", Count: 1, Milliseconds: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := timings.Slowest(tt.n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Slowest(%d) = %v, want %v", tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	site := NewSite(Config{})
+	site.Posts = []Post{{}, {}}
+	site.Tags = map[string][]Post{"go": site.Posts}
+	site.Cache.Hits, site.Cache.Misses = 3, 1
+	site.RenderTimes.Add("content/posts/a.md", 4*time.Millisecond)
+	builder := NewBuilder(t.TempDir(), "")
+	builder.Written, builder.Unchanged, builder.BytesWritten = 2, 5, 2048
+	templates := NewTimings()
+	templates.Add("post.html", time.Millisecond)
+
+	report := NewReport()
+	report.Phase("load", time.Now())
+	report.Finish(site, builder, templates)
+
+	var table bytes.Buffer
+	if err := report.WriteTable(&table); err != nil {
+		t.Fatalf("WriteTable() error = %v", err)
+	}
+	for _, want := range []string{"posts     2", "hits    3 (75%)", "written    2 (2.0 KB)", "content/posts/a.md  4.0ms", "load"} {
+		if !strings.Contains(table.String(), want) {
+			t.Errorf("WriteTable() = %q, want it to contain %q", table.String(), want)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := report.WriteJSON(&out); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("WriteJSON() wrote invalid JSON: %v", err)
+	}
+	if decoded.Cache.HitRate != 0.75 || decoded.Outputs.BytesWritten != 2048 || decoded.Sections["tags"] != 1 {
+		t.Errorf("WriteJSON() = %s", out.String())
+	}
+}
@@ -0,0 +1,346 @@
+package src
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Shortcodes expands {{< name key="value" >}} tags in Markdown with Go
+// templates. The theme's built-in shortcodes can be overridden, and new ones
+// added, by files in the site's layouts/shortcodes directory.
+type Shortcodes struct {
+	tmpl *template.Template
+	// root is the directory files are included from.
+	root string
+	// hash identifies the templates, see HashFS.
+	hash string
+}
+
+// Shortcode is the data a shortcode template is executed with.
+type Shortcode struct {
+	Name string
+	// Params holds the named parameters and Positional the unnamed ones.
+	Params     map[string]string
+	Positional []string
+	// Inner is the content between a paired shortcode's tags, rendered as
+	// Markdown. InnerRaw is the same content as written.
+	Inner    template.HTML
+	InnerRaw string
+
+	root string
+	// includes records the hash of every file included, by path.
+	includes map[string]string
+}
+
+// LoadShortcodes parses the shortcode templates in dir of fsys, then those in
+// userDir, which may be missing. Included files are read from root.
+func LoadShortcodes(fsys fs.FS, dir, userDir, root string, funcs template.FuncMap) (*Shortcodes, error) {
+	s := &Shortcodes{
+		tmpl: template.New("shortcodes").Funcs(funcs),
+		root: root,
+		hash: HashFS(fsys, dir) + HashFS(os.DirFS(userDir), "."),
+	}
+	if err := parseTemplateDir(s.tmpl, fsys, dir); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(userDir); err == nil {
+		if err := parseTemplateDir(s.tmpl, os.DirFS(userDir), "."); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// placeholder marks where the output of shortcode i goes. It is plain text
+// that Markdown leaves alone.
+func placeholder(i int) string {
+	return fmt.Sprintf("GOSSGSHORTCODE%dEND", i)
+}
+
+// extract replaces every shortcode in source with a placeholder and returns
+// the rendered shortcodes in placeholder order. {{</* name */>}} is kept as
+// the literal text {{< name >}}. Included files are recorded in includes.
+func (s *Shortcodes) extract(source string, includes map[string]string) (string, []string, error) {
+	var out strings.Builder
+	var rendered []string
+	pos := 0
+	for {
+		start := strings.Index(source[pos:], "{{<")
+		if start < 0 {
+			out.WriteString(source[pos:])
+			return out.String(), rendered, nil
+		}
+		start += pos
+		out.WriteString(source[pos:start])
+
+		tag, n, err := parseShortcodeTag(source[start:])
+		if err != nil {
+			return "", nil, &FileError{Line: lineOf(source, start), Err: err}
+		}
+		pos = start + n
+
+		if tag.comment {
+			out.WriteString("{{<" + tag.raw + ">}}")
+			continue
+		}
+		if tag.closing {
+			return "", nil, &FileError{Line: lineOf(source, start), Err: fmt.Errorf("closing shortcode %q without an opening one", tag.name)}
+		}
+
+		sc := &Shortcode{Name: tag.name, Params: tag.params, Positional: tag.positional, root: s.root, includes: includes}
+		if inner, n, ok := findClosingTag(source[pos:], tag.name); ok {
+			html, err := s.renderInner(inner, includes)
+			if err != nil {
+				return "", nil, &FileError{Line: lineOf(source, start), Err: err}
+			}
+			sc.Inner, sc.InnerRaw = template.HTML(html), inner
+			pos += n
+		}
+
+		html, err := s.render(sc)
+		if err != nil {
+			return "", nil, &FileError{Line: lineOf(source, start), Err: err}
+		}
+		out.WriteString(placeholder(len(rendered)))
+		rendered = append(rendered, html)
+	}
+}
+
+// restore puts the rendered shortcodes back in place of their placeholders.
+func restore(html string, rendered []string) string {
+	for i, r := range rendered {
+		p := placeholder(i)
+		// A shortcode on a line of its own becomes a paragraph
+		html = strings.Replace(html, "<p>"+p+"</p>", r, 1)
+		html = strings.Replace(html, p, r, 1)
+	}
+	return html
+}
+
+// restoreText puts the text of the rendered shortcodes, without their tags,
+// back in place of their placeholders in text, as in a heading title.
+func restoreText(text string, rendered []string) string {
+	for i, r := range rendered {
+		text = strings.Replace(text, placeholder(i), html.UnescapeString(plainify(r)), 1)
+	}
+	return text
+}
+
+func (s *Shortcodes) render(sc *Shortcode) (string, error) {
+	t := s.tmpl.Lookup(sc.Name + ".html")
+	if t == nil {
+		return "", fmt.Errorf("unknown shortcode %q", sc.Name)
+	}
+	var buf strings.Builder
+	if err := t.Execute(&buf, sc); err != nil {
+		return "", fmt.Errorf("shortcode %q: %w", sc.Name, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// renderInner renders the Markdown inside a paired shortcode, which may hold
+// shortcodes of its own.
+func (s *Shortcodes) renderInner(inner string, includes map[string]string) (string, error) {
+	source, rendered, err := s.extract(inner, includes)
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	if err := markdown.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return restore(buf.String(), rendered), nil
+}
+
+type shortcodeTag struct {
+	name       string
+	params     map[string]string
+	positional []string
+	closing    bool
+	comment    bool
+	raw        string // the text between {{< and >}} of a comment
+}
+
+// parseShortcodeTag parses the tag at the start of s and returns it with
+// the length of its text.
+func parseShortcodeTag(s string) (shortcodeTag, int, error) {
+	tag := shortcodeTag{params: map[string]string{}}
+	i := len("{{<")
+
+	if strings.HasPrefix(s[i:], "/*") {
+		end := strings.Index(s[i:], "*/>}}")
+		if end < 0 {
+			return tag, 0, fmt.Errorf("unterminated shortcode comment")
+		}
+		tag.comment = true
+		tag.raw = s[i+2 : i+end]
+		return tag, i + end + len("*/>}}"), nil
+	}
+
+	skipSpace := func() {
+		for i < len(s) && unicode.IsSpace(rune(s[i])) {
+			i++
+		}
+	}
+	skipSpace()
+	if i < len(s) && s[i] == '/' {
+		tag.closing = true
+		i++
+		skipSpace()
+	}
+	tag.name, i = readWord(s, i)
+	if tag.name == "" {
+		return tag, 0, fmt.Errorf("shortcode without a name")
+	}
+
+	for {
+		skipSpace()
+		if strings.HasPrefix(s[i:], ">}}") {
+			return tag, i + len(">}}"), nil
+		}
+		if i >= len(s) {
+			return tag, 0, fmt.Errorf("unterminated shortcode %q", tag.name)
+		}
+
+		var value string
+		var err error
+		if s[i] == '"' {
+			value, i, err = readQuoted(s, i)
+			if err != nil {
+				return tag, 0, err
+			}
+			tag.positional = append(tag.positional, value)
+			continue
+		}
+		var word string
+		word, i = readWord(s, i)
+		if word == "" {
+			return tag, 0, fmt.Errorf("unexpected %q in shortcode %q", s[i], tag.name)
+		}
+		if i < len(s) && s[i] == '=' {
+			i++
+			if i < len(s) && s[i] == '"' {
+				value, i, err = readQuoted(s, i)
+				if err != nil {
+					return tag, 0, err
+				}
+			} else {
+				value, i = readWord(s, i)
+			}
+			tag.params[word] = value
+			continue
+		}
+		tag.positional = append(tag.positional, word)
+	}
+}
+
+// readWord reads an unquoted name or value starting at i.
+func readWord(s string, i int) (string, int) {
+	start := i
+	for i < len(s) && !unicode.IsSpace(rune(s[i])) && s[i] != '=' && s[i] != '"' && !strings.HasPrefix(s[i:], ">}}") {
+		i++
+	}
+	return s[start:i], i
+}
+
+// readQuoted reads the double-quoted string starting at i, where \" and \\
+// are escapes.
+func readQuoted(s string, i int) (string, int, error) {
+	var sb strings.Builder
+	for i++; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+			}
+		case '"':
+			return sb.String(), i + 1, nil
+		}
+		sb.WriteByte(s[i])
+	}
+	return "", 0, fmt.Errorf("unterminated string in shortcode")
+}
+
+// findClosingTag looks for {{< /name >}} in s, returning the text before it
+// and the length of s up to the end of the closing tag.
+func findClosingTag(s, name string) (string, int, bool) {
+	for offset := 0; ; {
+		start := strings.Index(s[offset:], "{{<")
+		if start < 0 {
+			return "", 0, false
+		}
+		start += offset
+		tag, n, err := parseShortcodeTag(s[start:])
+		if err == nil && tag.closing && tag.name == name {
+			return s[:start], start + n, true
+		}
+		offset = start + len("{{<")
+	}
+}
+
+func lineOf(s string, offset int) int {
+	return strings.Count(s[:offset], "\n") + 1
+}
+
+// Get returns a named parameter, or a positional one when key is an int.
+func (sc *Shortcode) Get(key any) string {
+	switch k := key.(type) {
+	case string:
+		return sc.Params[k]
+	case int:
+		if k >= 0 && k < len(sc.Positional) {
+			return sc.Positional[k]
+		}
+	}
+	return ""
+}
+
+// Ext returns the extension of file without the dot, for guessing the
+// language of included code.
+func (sc *Shortcode) Ext(file string) string {
+	return strings.TrimPrefix(filepath.Ext(file), ".")
+}
+
+// Include returns the content of file, a path relative to the site root. If
+// lines is set ("5" or "3-10") only those lines are returned.
+func (sc *Shortcode) Include(file, lines string) (string, error) {
+	if !filepath.IsLocal(file) {
+		return "", fmt.Errorf("include: %q is outside the site", file)
+	}
+	path := filepath.Join(sc.root, file)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("include: %w", err)
+	}
+	if sc.includes != nil {
+		sc.includes[path] = ComputeHash(content)
+	}
+	text := strings.TrimRight(string(content), "\n")
+	if lines == "" {
+		return text, nil
+	}
+
+	from, to, found := strings.Cut(lines, "-")
+	first, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil {
+		return "", fmt.Errorf("include: invalid lines %q", lines)
+	}
+	last := first
+	if found {
+		if last, err = strconv.Atoi(strings.TrimSpace(to)); err != nil {
+			return "", fmt.Errorf("include: invalid lines %q", lines)
+		}
+	}
+	all := strings.Split(text, "\n")
+	if first < 1 || last < first || last > len(all) {
+		return "", fmt.Errorf("include: lines %q out of range for %s", lines, file)
+	}
+	return strings.Join(all[first-1:last], "\n"), nil
+}
@@ -0,0 +1,115 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iashyam/gossg/src/parser"
+)
+
+func TestShortcodes(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "solver.py"), []byte("a = 1\nb = 2\nprint(a < b)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	userDir := filepath.Join(root, "shortcodes")
+	if err := os.Mkdir(userDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(userDir, "badge.html"), []byte(`<span class="badge">{{ .Get 0 }}</span>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{BaseURL: "https://example.com"}
+	shortcodes, err := LoadShortcodes(os.DirFS("templates"), "shortcodes", userDir, root, FuncMap(cfg))
+	if err != nil {
+		t.Fatalf("LoadShortcodes() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []string // fragments expected in the HTML
+		toc      string   // fragment expected in the table of contents
+		wantErr  bool
+	}{
+		{
+			name:  "Figure",
+			input: `{{< figure src="/assets/plot.png" caption="The *plot*" >}}`,
+			expected: []string{
+				`<figure class="my-8">`,
+				`<img src="https://example.com/assets/plot.png" alt="The plot"`,
+				`<figcaption class="mt-3 text-center text-sm text-gray-500 dark:text-gray-400">The <em>plot</em></figcaption>`,
+			},
+		},
+		{
+			name:     "YouTube with a positional id",
+			input:    "Watch:\n\n{{< youtube abc123 >}}\n",
+			expected: []string{"<p>Watch:</p>\n<div", `src="https://www.youtube-nocookie.com/embed/abc123"`},
+		},
+		{
+			name:     "Include lines",
+			input:    `{{< include file="solver.py" lines="2-3" >}}`,
+			expected: []string{"<pre><code class=\"language-py\">b = 2\nprint(a &lt; b)</code></pre>"},
+		},
+		{
+			name:    "Include outside the site",
+			input:   `{{< include "../secret.txt" >}}`,
+			wantErr: true,
+		},
+		{
+			name:  "Paired notice with Markdown inside",
+			input: "{{< notice type=\"tip\" title=\"Tip\" >}}\nUse **go vet**.\n{{< /notice >}}",
+			expected: []string{
+				`<aside class="notice notice-tip">`,
+				`<p class="notice-title">Tip</p>`,
+				`<p>Use <strong>go vet</strong>.</p>`,
+			},
+		},
+		{
+			name:     "User shortcode inline",
+			input:    `Status: {{< badge "stable" >}} now.`,
+			expected: []string{`<p>Status: <span class="badge">stable</span> now.</p>`},
+		},
+		{
+			name:     "Shortcode in a heading",
+			input:    `## Status {{< badge "stable & tested" >}}`,
+			expected: []string{`<h2 id="status-stable-tested">Status <span class="badge">stable &amp; tested</span>`},
+			toc:      `<a href="#status-stable-tested">Status stable &amp; tested</a>`,
+		},
+		{
+			name:     "Escaped shortcode",
+			input:    "`{{</* youtube abc */>}}`",
+			expected: []string{`<code>{{&lt; youtube abc &gt;}}</code>`},
+		},
+		{
+			name:    "Unknown shortcode",
+			input:   "Intro\n\n{{< tweet 1 >}}",
+			wantErr: true,
+		},
+		{
+			name:    "Unterminated shortcode",
+			input:   `{{< figure src="x.png"`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := renderMarkdown(tt.input, parser.Frontmatter{}, cfg, shortcodes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renderMarkdown() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, fragment := range tt.expected {
+				if !strings.Contains(out.HTML, fragment) {
+					t.Errorf("HTML = %q, missing %q", out.HTML, fragment)
+				}
+			}
+			if !strings.Contains(string(out.TableOfContents), tt.toc) {
+				t.Errorf("TableOfContents = %q, missing %q", out.TableOfContents, tt.toc)
+			}
+		})
+	}
+}
@@ -1,18 +1,19 @@
 package src
 
 import (
+	"errors"
 	"fmt"
 	"html/template"
+	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/iashyam/gossg/src/parser"
-
-	mathjax "github.com/litao91/goldmark-mathjax"
-	"github.com/yuin/goldmark"
 )
 
 func parseDateVals(dateStr string) (string, string) {
@@ -25,23 +26,41 @@ func parseDateVals(dateStr string) (string, string) {
 // Post represents a blog post
 type Post struct {
 	parser.Frontmatter
-	ContentHTML  template.HTML
-	Slug         string
-	Year         string
-	MonthDayDesc string
+	ContentHTML     template.HTML
+	TableOfContents template.HTML
+	Headings        []Heading
+	WordCount       int
+	ReadingTime     int // minutes
+	Authors         []*Author
+	Related         []*Post  // similar posts, most similar first
+	File            string   // path of the Markdown source
+	Includes        []string // files included by shortcodes
+	Slug            string
+	Year            string
+	MonthDayDesc    string
 }
 
 // Page represents a standalone page (like about, contact)
 type Page struct {
 	parser.Frontmatter
-	ContentHTML template.HTML
-	Slug        string
+	ContentHTML     template.HTML
+	TableOfContents template.HTML
+	Headings        []Heading
+	WordCount       int
+	ReadingTime     int // minutes
+	Authors         []*Author
+	Related         []*Post  // always empty, so pages can share post.html
+	File            string   // path of the Markdown source
+	Includes        []string // files included by shortcodes
+	Slug            string
 }
 
 // Project represents a project to display on the projects page
 type Project struct {
 	parser.Frontmatter
 	ContentHTML template.HTML
+	File        string   // path of the Markdown source
+	Includes    []string // files included by shortcodes
 	Slug        string
 }
 
@@ -51,52 +70,138 @@ type Site struct {
 	Pages    []Page
 	Projects []Project
 	Tags     map[string][]Post
-	Cache    *Cache
+	Authors  map[string]*Author
+	// Data holds the files of the data directory, see loadData.
+	Data   map[string]any
+	Cache  *Cache
+	Config Config
+	// Shortcodes expands shortcodes in content. Without it they are left
+	// as written.
+	Shortcodes *Shortcodes
+	// Partials are the theme's template fragments, which shortcodes can
+	// render too.
+	Partials *Partials
+	// Workers is the number of files rendered at once, one per CPU when 0.
+	Workers int
+	// RenderTimes records how long each Markdown file took to render.
+	RenderTimes *Timings
+	// Assets are the static files published under assets.
+	Assets *Assets
 }
 
-func NewSite() *Site {
+func NewSite(cfg Config) *Site {
 	return &Site{
-		Posts:    []Post{},
-		Pages:    []Page{},
-		Projects: []Project{},
-		Tags:     make(map[string][]Post),
-		Cache:    NewCache(".gossg_cache.json"),
+		Posts:       []Post{},
+		Pages:       []Page{},
+		Projects:    []Project{},
+		Tags:        make(map[string][]Post),
+		Authors:     make(map[string]*Author),
+		Data:        make(map[string]any),
+		Cache:       NewCache(NewJSONStore(".gossg_cache.json")),
+		Config:      cfg,
+		RenderTimes: NewTimings(),
+		Assets:      NewAssets("assets", cfg),
 	}
 }
 
 func (s *Site) LoadContent(contentDir string) error {
-	// Load cache from disk
+	// Load cache from disk, keeping only entries rendered the way this
+	// build renders them
+	s.Cache.Fingerprint = s.CacheFingerprint()
 	if err := s.Cache.Load(); err != nil {
-		fmt.Printf("Warning: failed to load cache: %v\n", err)
+		slog.Warn("failed to load cache", "err", err)
+	}
+	// Data files sit next to the content directory
+	dataDir := filepath.Join(filepath.Dir(contentDir), "data")
+	data, err := loadData(dataDir)
+	if err != nil {
+		return fmt.Errorf("error loading data: %w", err)
 	}
+	s.Data = data
+
+	// Authors are resolved while loading posts and pages
+	if err := s.loadAuthors(filepath.Join(dataDir, "authors.yaml")); err != nil {
+		return fmt.Errorf("error loading authors: %w", err)
+	}
+
+	// Every section is loaded, even after errors, so that one build
+	// reports all broken files
+	var errs []error
+
 	// 1. Load Posts
 	postsDir := filepath.Join(contentDir, "posts")
-	if err := s.loadPosts(postsDir); err != nil {
-		return fmt.Errorf("error loading posts: %w", err)
-	}
+	errs = append(errs, s.loadPosts(postsDir))
+
+	s.computeRelated()
 
 	// 2. Load Pages
 	pagesDir := filepath.Join(contentDir, "pages")
-	if err := s.loadPages(pagesDir); err != nil {
-		return fmt.Errorf("error loading pages: %w", err)
-	}
+	errs = append(errs, s.loadPages(pagesDir))
 
 	// 3. Load Projects
 	projectsDir := filepath.Join(contentDir, "projects")
-	if err := s.loadProjects(projectsDir); err != nil {
-		return fmt.Errorf("error loading projects: %w", err)
-	}
+	errs = append(errs, s.loadProjects(projectsDir))
 
-	// Save cache back to disk
+	// Save cache back to disk, without the files that are gone
+	if removed := s.Cache.RemoveUnused(); removed > 0 {
+		slog.Debug("removed unused cache entries", "count", removed)
+	}
 	if err := s.Cache.Save(); err != nil {
-		fmt.Printf("Warning: failed to save cache: %v\n", err)
+		slog.Warn("failed to save cache", "err", err)
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
-func (s *Site) loadPosts(dir string) error {
-	files, err := os.ReadDir(dir)
+// loadFile returns the cached rendering of the Markdown file at path,
+// rendering it and updating the cache when its content has changed.
+func (s *Site) loadFile(path string, content []byte) (CachedFile, error) {
+	hash := ComputeHash(content)
+	if cachedFile, hit := s.Cache.Get(path, hash); hit {
+		// Cache Hit: file hasn't changed, skip Lexing and Parsing
+		slog.Debug("cache hit", "file", path)
+		return cachedFile, nil
+	}
+
+	// Cache Miss: extract, parse, and update cache
+	slog.Debug("cache miss, rendering", "file", path)
+	start := time.Now()
+	defer func() { s.RenderTimes.Add(path, time.Since(start)) }()
+	fm, textContent, err := parser.ExtractFrontmatter(string(content))
+	if err != nil {
+		return CachedFile{}, &FileError{File: path, Line: yamlLine(err), Err: fmt.Errorf("failed to parse frontmatter: %w", err)}
+	}
+
+	out, err := renderMarkdown(textContent, fm, s.Config, s.Shortcodes)
+	if err != nil {
+		// Lines in the Markdown are counted from the end of the frontmatter
+		var lineErr *FileError
+		if errors.As(err, &lineErr) {
+			bodyStart := strings.Count(string(content[:strings.LastIndex(string(content), textContent)]), "\n")
+			return CachedFile{}, &FileError{File: path, Line: bodyStart + lineErr.Line, Err: lineErr.Err}
+		}
+		return CachedFile{}, &FileError{File: path, Err: fmt.Errorf("failed to convert markdown: %w", err)}
+	}
+
+	cachedFile := CachedFile{
+		Hash:            hash,
+		Frontmatter:     fm,
+		ContentHTML:     out.HTML,
+		TableOfContents: out.TableOfContents,
+		Headings:        out.Headings,
+		WordCount:       out.WordCount,
+		ReadingTime:     out.ReadingTime,
+		Includes:        out.Includes,
+	}
+	s.Cache.Put(path, cachedFile)
+	return cachedFile, nil
+}
+
+// loadDir loads the Markdown files in dir, rendering up to s.Workers of
+// them at once, and calls add for each in directory order. Files that fail
+// to load are skipped and their errors, each a *FileError, joined.
+func (s *Site) loadDir(dir string, add func(path, name string, cachedFile CachedFile)) error {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil // Directory doesn't exist, that's fine
@@ -104,66 +209,49 @@ func (s *Site) loadPosts(dir string) error {
 		return err
 	}
 
-	for _, file := range files {
-		if file.IsDir() || !strings.HasSuffix(file.Name(), ".md") {
-			continue
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".md") {
+			names = append(names, entry.Name())
 		}
+	}
 
-		path := filepath.Join(dir, file.Name())
+	cachedFiles := make([]CachedFile, len(names))
+	errs := make([]error, len(names))
+	Parallel(len(names), s.Workers, func(i int) {
+		path := filepath.Join(dir, names[i])
 		content, err := os.ReadFile(path)
 		if err != nil {
-			return err
+			errs[i] = &FileError{File: path, Err: err}
+			return
 		}
+		cachedFiles[i], errs[i] = s.loadFile(path, content)
+	})
 
-		hash := ComputeHash(content)
-		slug := strings.ReplaceAll(strings.TrimSuffix(file.Name(), ".md"), " ", "-")
-
-		var post Post
-		if cachedFile, hit := s.Cache.Files[path]; hit && cachedFile.Hash == hash {
-			// Cache Hit: file hasn't changed, skip Lexing and Parsing
-			fmt.Printf("Cache hit: %s\n", path)
-			y, md := parseDateVals(cachedFile.Frontmatter.Date)
-			post = Post{
-				Frontmatter:  cachedFile.Frontmatter,
-				ContentHTML:  template.HTML(cachedFile.ContentHTML),
-				Slug:         slug,
-				Year:         y,
-				MonthDayDesc: md,
-			}
-		} else {
-			// Cache Miss: extract, parse, and update cache
-			fmt.Printf("Cache miss: parsing %s...\n", path)
-			fm, textContent, err := parser.ExtractFrontmatter(string(content))
-			if err != nil {
-				fmt.Printf("Warning: failed to parse frontmatter for %s: %v\n", path, err)
-				continue
-			}
-
-			// Parse Markdown to HTML
-			var buf strings.Builder
-			md := goldmark.New(
-				goldmark.WithExtensions(mathjax.MathJax),
-			)
-			if err := md.Convert([]byte(textContent), &buf); err != nil {
-				fmt.Printf("Warning: failed to convert markdown for %s: %v\n", path, err)
-				continue
-			}
-			htmlContent := buf.String()
-
-			s.Cache.Files[path] = CachedFile{
-				Hash:        hash,
-				Frontmatter: fm,
-				ContentHTML: htmlContent,
-			}
-
-			y, monthDay := parseDateVals(fm.Date)
-			post = Post{
-				Frontmatter:  fm,
-				ContentHTML:  template.HTML(htmlContent),
-				Slug:         slug,
-				Year:         y,
-				MonthDayDesc: monthDay,
-			}
+	for i, name := range names {
+		if errs[i] == nil {
+			add(filepath.Join(dir, name), name, cachedFiles[i])
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Site) loadPosts(dir string) error {
+	err := s.loadDir(dir, func(path, name string, cachedFile CachedFile) {
+		y, monthDay := parseDateVals(cachedFile.Frontmatter.Date)
+		post := Post{
+			Frontmatter:     cachedFile.Frontmatter,
+			ContentHTML:     template.HTML(cachedFile.ContentHTML),
+			TableOfContents: template.HTML(cachedFile.TableOfContents),
+			Headings:        cachedFile.Headings,
+			WordCount:       cachedFile.WordCount,
+			ReadingTime:     cachedFile.ReadingTime,
+			Authors:         s.resolveAuthors(cachedFile.Frontmatter),
+			File:            path,
+			Includes:        slices.Sorted(maps.Keys(cachedFile.Includes)),
+			Slug:            strings.ReplaceAll(strings.TrimSuffix(name, ".md"), " ", "-"),
+			Year:            y,
+			MonthDayDesc:    monthDay,
 		}
 
 		s.Posts = append(s.Posts, post)
@@ -172,154 +260,50 @@ func (s *Site) loadPosts(dir string) error {
 		for _, tag := range post.Frontmatter.Tags {
 			s.Tags[tag] = append(s.Tags[tag], post)
 		}
-	}
-
-	// Sort posts by date descending (newest first)
-	sort.Slice(s.Posts, func(i, j int) bool {
-		return s.Posts[i].Date > s.Posts[j].Date
 	})
-
-	return nil
-}
-
-func (s *Site) loadPages(dir string) error {
-	files, err := os.ReadDir(dir)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
-		}
 		return err
 	}
 
-	for _, file := range files {
-		if file.IsDir() || !strings.HasSuffix(file.Name(), ".md") {
-			continue
-		}
-
-		path := filepath.Join(dir, file.Name())
-		content, err := os.ReadFile(path)
-		if err != nil {
-			return err
-		}
+	// Sort posts by date descending (newest first)
+	sort.SliceStable(s.Posts, func(i, j int) bool {
+		return s.Posts[i].Date > s.Posts[j].Date
+	})
 
-		hash := ComputeHash(content)
-		slug := strings.ReplaceAll(strings.TrimSuffix(file.Name(), ".md"), " ", "-")
-
-		var page Page
-		if cachedFile, hit := s.Cache.Files[path]; hit && cachedFile.Hash == hash {
-			// Cache Hit: file hasn't changed, skip Lexing and Parsing
-			fmt.Printf("Cache hit: %s\n", path)
-			page = Page{
-				Frontmatter: cachedFile.Frontmatter,
-				ContentHTML: template.HTML(cachedFile.ContentHTML),
-				Slug:        slug,
-			}
-		} else {
-			// Cache Miss: extract, parse, and update cache
-			fmt.Printf("Cache miss: parsing %s...\n", path)
-			fm, textContent, err := parser.ExtractFrontmatter(string(content))
-			if err != nil {
-				fmt.Printf("Warning: failed to parse frontmatter for %s: %v\n", path, err)
-				continue
-			}
-
-			// Parse Markdown to HTML
-			var buf strings.Builder
-			md := goldmark.New(
-				goldmark.WithExtensions(mathjax.MathJax),
-			)
-			if err := md.Convert([]byte(textContent), &buf); err != nil {
-				fmt.Printf("Warning: failed to convert markdown for %s: %v\n", path, err)
-				continue
-			}
-			htmlContent := buf.String()
-
-			s.Cache.Files[path] = CachedFile{
-				Hash:        hash,
-				Frontmatter: fm,
-				ContentHTML: htmlContent,
-			}
-
-			page = Page{
-				Frontmatter: fm,
-				ContentHTML: template.HTML(htmlContent),
-				Slug:        slug,
-			}
+	for _, post := range s.Posts {
+		for _, author := range post.Authors {
+			author.Posts = append(author.Posts, post)
 		}
-
-		s.Pages = append(s.Pages, page)
 	}
 
 	return nil
 }
 
-func (s *Site) loadProjects(dir string) error {
-	files, err := os.ReadDir(dir)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
-		}
-		return err
-	}
-
-	for _, file := range files {
-		if file.IsDir() || !strings.HasSuffix(file.Name(), ".md") {
-			continue
-		}
-
-		path := filepath.Join(dir, file.Name())
-		content, err := os.ReadFile(path)
-		if err != nil {
-			return err
-		}
-
-		hash := ComputeHash(content)
-		slug := strings.ReplaceAll(strings.TrimSuffix(file.Name(), ".md"), " ", "-")
-
-		var project Project
-		if cachedFile, hit := s.Cache.Files[path]; hit && cachedFile.Hash == hash {
-			// Cache Hit
-			fmt.Printf("Cache hit: %s\n", path)
-			project = Project{
-				Frontmatter: cachedFile.Frontmatter,
-				ContentHTML: template.HTML(cachedFile.ContentHTML),
-				Slug:        slug,
-			}
-		} else {
-			// Cache Miss
-			fmt.Printf("Cache miss: parsing %s...\n", path)
-			fm, textContent, err := parser.ExtractFrontmatter(string(content))
-			if err != nil {
-				fmt.Printf("Warning: failed to parse frontmatter for %s: %v\n", path, err)
-				continue
-			}
-
-			// Parse Markdown to HTML
-			var buf strings.Builder
-			md := goldmark.New(
-				goldmark.WithExtensions(mathjax.MathJax),
-			)
-			if err := md.Convert([]byte(textContent), &buf); err != nil {
-				fmt.Printf("Warning: failed to convert markdown for %s: %v\n", path, err)
-				continue
-			}
-			htmlContent := buf.String()
-
-			s.Cache.Files[path] = CachedFile{
-				Hash:        hash,
-				Frontmatter: fm,
-				ContentHTML: htmlContent,
-			}
-
-			project = Project{
-				Frontmatter: fm,
-				ContentHTML: template.HTML(htmlContent),
-				Slug:        slug,
-			}
-		}
-
-		s.Projects = append(s.Projects, project)
-	}
+func (s *Site) loadPages(dir string) error {
+	return s.loadDir(dir, func(path, name string, cachedFile CachedFile) {
+		s.Pages = append(s.Pages, Page{
+			Frontmatter:     cachedFile.Frontmatter,
+			ContentHTML:     template.HTML(cachedFile.ContentHTML),
+			TableOfContents: template.HTML(cachedFile.TableOfContents),
+			Headings:        cachedFile.Headings,
+			WordCount:       cachedFile.WordCount,
+			ReadingTime:     cachedFile.ReadingTime,
+			Authors:         s.resolveAuthors(cachedFile.Frontmatter),
+			File:            path,
+			Includes:        slices.Sorted(maps.Keys(cachedFile.Includes)),
+			Slug:            strings.ReplaceAll(strings.TrimSuffix(name, ".md"), " ", "-"),
+		})
+	})
+}
 
-	return nil
+func (s *Site) loadProjects(dir string) error {
+	return s.loadDir(dir, func(path, name string, cachedFile CachedFile) {
+		s.Projects = append(s.Projects, Project{
+			Frontmatter: cachedFile.Frontmatter,
+			ContentHTML: template.HTML(cachedFile.ContentHTML),
+			File:        path,
+			Includes:    slices.Sorted(maps.Keys(cachedFile.Includes)),
+			Slug:        strings.ReplaceAll(strings.TrimSuffix(name, ".md"), " ", "-"),
+		})
+	})
 }
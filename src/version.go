@@ -0,0 +1,70 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// Version is the gossg release. Release builds set it with
+// -ldflags "-X github.com/iashyam/gossg/src.Version=v1.2.0".
+var Version = "dev"
+
+// BuildVersion identifies the gossg binary: its release, the revision it was
+// built from and the versions of its dependencies. Development builds also
+// include a hash of the executable, since their code changes between
+// revisions.
+func BuildVersion() string {
+	parts := []string{Version}
+	dirty := true
+	if info, ok := debug.ReadBuildInfo(); ok {
+		settings := map[string]string{}
+		for _, s := range info.Settings {
+			settings[s.Key] = s.Value
+		}
+		if rev := settings["vcs.revision"]; rev != "" {
+			parts = append(parts, rev)
+			dirty = settings["vcs.modified"] == "true"
+		}
+		var deps []string
+		for _, dep := range info.Deps {
+			deps = append(deps, dep.Path+"@"+dep.Version)
+		}
+		sort.Strings(deps)
+		parts = append(parts, deps...)
+	}
+	if Version == "dev" && dirty {
+		if exe, err := os.Executable(); err == nil {
+			if content, err := os.ReadFile(exe); err == nil {
+				parts = append(parts, ComputeHash(content))
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// CacheFingerprint identifies everything besides a file's content that its
+// cached rendering depends on: the gossg binary, the settings used while
+// rendering Markdown and the shortcode templates along with the partials
+// they can render.
+func (s *Site) CacheFingerprint() string {
+	settings, _ := json.Marshal(struct {
+		BaseURL     string
+		SiteName    string
+		TOC         TOCConfig
+		ReadingTime ReadingTimeConfig
+	}{s.Config.BaseURL, s.Config.SiteName, s.Config.TOC.withDefaults(), s.Config.ReadingTime})
+
+	shortcodes := ""
+	if s.Shortcodes != nil {
+		shortcodes = s.Shortcodes.hash
+	}
+	partials := ""
+	if s.Partials != nil {
+		partials = s.Partials.hash
+	}
+	return ComputeHash(fmt.Appendf(nil, "%s\x00%s\x00%s\x00%s", BuildVersion(), settings, shortcodes, partials))
+}